@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Aria2Downloader 通过 aria2 JSON-RPC 下载
+// aria2 的gid在获取磁力元数据后、重试后都会变化，因此任务ID统一使用infohash，查询时再找到对应的gid
+type Aria2Downloader struct {
+	config    DownloaderConfig
+	rpcURL    string
+	client    *http.Client
+	requestID int64
+}
+
+// aria2Status tellStatus 等接口返回的下载信息
+type aria2Status struct {
+	GID             string   `json:"gid"`
+	Status          string   `json:"status"` // active / waiting / paused / error / complete / removed
+	TotalLength     string   `json:"totalLength"`
+	CompletedLength string   `json:"completedLength"`
+	InfoHash        string   `json:"infoHash"`
+	Dir             string   `json:"dir"`
+	ErrorMessage    string   `json:"errorMessage"`
+	FollowedBy      []string `json:"followedBy"`
+	Bittorrent      struct {
+		Info struct {
+			Name string `json:"name"`
+		} `json:"info"`
+	} `json:"bittorrent"`
+}
+
+// aria2Keys 查询时需要的字段
+var aria2Keys = []string{
+	"gid", "status", "totalLength", "completedLength", "infoHash", "dir",
+	"errorMessage", "followedBy", "bittorrent",
+}
+
+// NewAria2Downloader 创建aria2下载器，url 未包含路径时使用默认的 /jsonrpc
+func NewAria2Downloader(config DownloaderConfig) *Aria2Downloader {
+	rpcURL := strings.TrimSuffix(config.URL, "/")
+	if !strings.HasSuffix(rpcURL, "/jsonrpc") {
+		rpcURL += "/jsonrpc"
+	}
+
+	return &Aria2Downloader{
+		config: config,
+		rpcURL: rpcURL,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name 下载器名称
+func (a *Aria2Downloader) Name() string {
+	return a.config.Name
+}
+
+// call 调用RPC方法，配置了 token 时作为第一个参数传入 token:<rpc-secret>
+func (a *Aria2Downloader) call(method string, result interface{}, params ...interface{}) error {
+	if a.config.Token != "" {
+		params = append([]interface{}{"token:" + a.config.Token}, params...)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      strconv.FormatInt(atomic.AddInt64(&a.requestID, 1), 10),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return fmt.Errorf("JSON编码请求失败: %v", err)
+	}
+
+	resp, err := a.client.Post(a.rpcURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("请求aria2失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return fmt.Errorf("解析aria2响应失败: HTTP %d", resp.StatusCode)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("aria2返回错误: %s (code %d)", response.Error.Message, response.Error.Code)
+	}
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("解析aria2响应失败: %v", err)
+		}
+	}
+	return nil
+}
+
+// ResolveFolder 本地下载器直接使用目录路径，未指定时使用配置的保存目录
+func (a *Aria2Downloader) ResolveFolder(folderPath string) (string, error) {
+	if folderPath == "" {
+		return a.config.SavePath, nil
+	}
+	return folderPath, nil
+}
+
+// AddTask 添加磁力链接或种子链接
+// 种子地址中没有infohash时，下载种子文件计算infohash，并通过 aria2.addTorrent 直接上传种子文件
+func (a *Aria2Downloader) AddTask(name, link, folder string) (string, error) {
+	var torrent []byte
+	hash, err := linkInfoHash(link)
+	if err != nil {
+		if strings.HasPrefix(link, "magnet:") {
+			return "", err
+		}
+		data, meta, fetchErr := downloadTorrentFile(context.Background(), link)
+		if fetchErr != nil {
+			return "", fmt.Errorf("%v，下载种子文件失败: %v", err, fetchErr)
+		}
+		hash, torrent = meta.InfoHash, data
+	}
+
+	log.Printf("📥 添加aria2任务: %s", name)
+
+	if torrent != nil {
+		err = a.addTorrent(torrent, folder)
+	} else {
+		err = a.addURI(link, folder)
+	}
+	if err != nil {
+		return "", fmt.Errorf("添加aria2任务失败: %v", err)
+	}
+
+	log.Printf("✅ aria2任务添加成功: %s", hash)
+	return hash, nil
+}
+
+// addOptions 添加任务时的选项
+func (a *Aria2Downloader) addOptions(folder string) map[string]string {
+	options := map[string]string{}
+	if folder != "" {
+		options["dir"] = folder
+	}
+	return options
+}
+
+// addURI 调用 aria2.addUri
+func (a *Aria2Downloader) addURI(link, folder string) error {
+	var gid string
+	return a.call("aria2.addUri", &gid, []string{link}, a.addOptions(folder))
+}
+
+// addTorrent 调用 aria2.addTorrent，种子文件以base64编码上传
+func (a *Aria2Downloader) addTorrent(torrent []byte, folder string) error {
+	var gid string
+	return a.call("aria2.addTorrent", &gid, base64.StdEncoding.EncodeToString(torrent), []string{}, a.addOptions(folder))
+}
+
+// GetTask 查询单个任务
+func (a *Aria2Downloader) GetTask(taskID string) (*DownloadTask, error) {
+	tasks, err := a.ListTasks()
+	if err != nil {
+		return nil, err
+	}
+	return findTaskIn(tasks, taskID)
+}
+
+// ListTasks 列出所有BT任务
+func (a *Aria2Downloader) ListTasks() ([]*DownloadTask, error) {
+	statuses, err := a.allStatuses()
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一infohash可能有多条记录（磁力元数据下载、重试前的失败记录），取最能代表当前状态的一条
+	best := make(map[string]*aria2Status)
+	var order []string
+	for i := range statuses {
+		s := &statuses[i]
+		if s.InfoHash == "" {
+			continue
+		}
+		hash := strings.ToLower(s.InfoHash)
+		current, ok := best[hash]
+		if !ok {
+			order = append(order, hash)
+		}
+		if !ok || aria2Rank(s) > aria2Rank(current) {
+			best[hash] = s
+		}
+	}
+
+	tasks := make([]*DownloadTask, 0, len(order))
+	for _, hash := range order {
+		tasks = append(tasks, aria2Task(hash, best[hash]))
+	}
+	return tasks, nil
+}
+
+// allStatuses 查询活动、等待和已停止的全部下载
+func (a *Aria2Downloader) allStatuses() ([]aria2Status, error) {
+	var active, waiting, stopped []aria2Status
+	if err := a.call("aria2.tellActive", &active, aria2Keys); err != nil {
+		return nil, fmt.Errorf("获取aria2任务列表失败: %v", err)
+	}
+	if err := a.call("aria2.tellWaiting", &waiting, 0, 1000, aria2Keys); err != nil {
+		return nil, fmt.Errorf("获取aria2任务列表失败: %v", err)
+	}
+	if err := a.call("aria2.tellStopped", &stopped, 0, 1000, aria2Keys); err != nil {
+		return nil, fmt.Errorf("获取aria2任务列表失败: %v", err)
+	}
+
+	statuses := append(active, waiting...)
+	return append(statuses, stopped...), nil
+}
+
+// aria2Rank 记录的优先级：进行中 > 已完成的实际下载 > 失败 > 已完成的元数据下载
+func aria2Rank(s *aria2Status) int {
+	switch s.Status {
+	case "active", "waiting", "paused":
+		return 4
+	case "complete":
+		if len(s.FollowedBy) > 0 {
+			return 1
+		}
+		return 3
+	case "error":
+		return 2
+	}
+	return 0
+}
+
+// aria2Task 将aria2的下载信息转换为统一的任务
+func aria2Task(hash string, s *aria2Status) *DownloadTask {
+	total, _ := strconv.ParseInt(s.TotalLength, 10, 64)
+	completed, _ := strconv.ParseInt(s.CompletedLength, 10, 64)
+
+	task := &DownloadTask{
+		ID:       hash,
+		Name:     s.Bittorrent.Info.Name,
+		FileSize: total,
+		Message:  s.ErrorMessage,
+	}
+	if task.Name != "" {
+		task.FileID = path.Join(s.Dir, task.Name)
+	}
+	if total > 0 {
+		task.Progress = int(completed * 100 / total)
+	}
+
+	switch s.Status {
+	case "complete":
+		if len(s.FollowedBy) > 0 {
+			// 只完成了磁力元数据，实际下载尚未开始
+			task.Phase = TaskPhasePending
+		} else {
+			task.Phase = TaskPhaseComplete
+		}
+	case "error", "removed":
+		task.Phase = TaskPhaseError
+	case "waiting":
+		task.Phase = TaskPhasePending
+	default:
+		task.Phase = TaskPhaseRunning
+	}
+	return task
+}
+
+// RemoveTask 删除任务，aria2不会删除已下载的文件
+func (a *Aria2Downloader) RemoveTask(taskID string, deleteFiles bool) error {
+	log.Printf("🗑️  删除aria2任务: %s (删除文件: %v)", taskID, deleteFiles)
+	if deleteFiles {
+		log.Printf("⚠️  aria2不支持删除已下载的文件，仅删除任务")
+	}
+
+	statuses, err := a.allStatuses()
+	if err != nil {
+		return fmt.Errorf("删除aria2任务失败: %v", err)
+	}
+
+	found := false
+	for _, s := range statuses {
+		if !strings.EqualFold(s.InfoHash, taskID) {
+			continue
+		}
+		found = true
+
+		switch s.Status {
+		case "active", "waiting", "paused":
+			if err := a.call("aria2.remove", nil, s.GID); err != nil {
+				return fmt.Errorf("删除aria2任务失败: %v", err)
+			}
+		default:
+			if err := a.call("aria2.removeDownloadResult", nil, s.GID); err != nil {
+				return fmt.Errorf("删除aria2任务失败: %v", err)
+			}
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("未找到任务: %s", taskID)
+	}
+	return nil
+}
+
+// RetryTask aria2不支持重试BT任务，清除失败记录后用磁力链接重新添加
+func (a *Aria2Downloader) RetryTask(taskID string) error {
+	log.Printf("🔄 重试aria2任务: %s", taskID)
+
+	statuses, err := a.allStatuses()
+	if err != nil {
+		return fmt.Errorf("重试aria2任务失败: %v", err)
+	}
+
+	var failed *aria2Status
+	for i := range statuses {
+		s := &statuses[i]
+		if !strings.EqualFold(s.InfoHash, taskID) {
+			continue
+		}
+		if aria2Rank(s) >= 3 {
+			return fmt.Errorf("任务未失败，无需重试: %s", taskID)
+		}
+		if s.Status == "error" {
+			failed = s
+		}
+	}
+	if failed == nil {
+		return fmt.Errorf("未找到失败的任务: %s", taskID)
+	}
+
+	if err := a.call("aria2.removeDownloadResult", nil, failed.GID); err != nil {
+		return fmt.Errorf("重试aria2任务失败: %v", err)
+	}
+	if err := a.addURI("magnet:?xt=urn:btih:"+strings.ToLower(taskID), failed.Dir); err != nil {
+		return fmt.Errorf("重试aria2任务失败: %v", err)
+	}
+	return nil
+}
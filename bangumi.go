@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bangumiSearchAPI Bangumi番组计划的条目搜索接口，type=2 为动画
+const bangumiSearchAPI = "https://api.bgm.tv/search/subject/%s?type=2&responseGroup=small&max_results=1"
+
+// descriptionImageRegex 描述HTML中的第一张图片
+var descriptionImageRegex = regexp.MustCompile(`<img[^>]+src=["']([^"']+)["']`)
+
+// BangumiPosters 从Bangumi查询番剧海报，结果按番剧名缓存（包括查不到的）
+type BangumiPosters struct {
+	client *http.Client
+	api    string
+	cache  map[string]string
+	mutex  sync.Mutex
+}
+
+// NewBangumiPosters 创建海报查询
+func NewBangumiPosters() *BangumiPosters {
+	return &BangumiPosters{
+		client: &http.Client{Timeout: 10 * time.Second},
+		api:    bangumiSearchAPI,
+		cache:  make(map[string]string),
+	}
+}
+
+// Poster 查询番剧的海报地址，查不到时返回空字符串
+func (bp *BangumiPosters) Poster(ctx context.Context, series string) (string, error) {
+	bp.mutex.Lock()
+	poster, ok := bp.cache[series]
+	bp.mutex.Unlock()
+	if ok {
+		return poster, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf(bp.api, url.PathEscape(series)), nil)
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %v", err)
+	}
+	// Bangumi API 要求设置User-Agent
+	req.Header.Set("User-Agent", "bangumipikpak/1.0")
+
+	resp, err := bp.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("查询Bangumi失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// 没有搜索结果时Bangumi返回404
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return "", fmt.Errorf("查询Bangumi失败，状态码: %d", resp.StatusCode)
+	}
+
+	var result struct {
+		List []struct {
+			Images struct {
+				Large  string `json:"large"`
+				Common string `json:"common"`
+			} `json:"images"`
+		} `json:"list"`
+	}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return "", fmt.Errorf("解析Bangumi响应失败: %v", err)
+		}
+	}
+
+	if len(result.List) > 0 {
+		poster = result.List[0].Images.Large
+		if poster == "" {
+			poster = result.List[0].Images.Common
+		}
+	}
+
+	bp.mutex.Lock()
+	bp.cache[series] = poster
+	bp.mutex.Unlock()
+	return poster, nil
+}
+
+// itemImage RSS项目自带的封面图片：media:thumbnail、图片类型的enclosure或描述中的第一张图片
+func itemImage(item FeedItem) string {
+	if item.Thumbnail != "" {
+		return item.Thumbnail
+	}
+	if strings.HasPrefix(item.Enclosure.Type, "image/") {
+		return item.Enclosure.URL
+	}
+	if match := descriptionImageRegex.FindStringSubmatch(item.Description); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// releaseImage 通知使用的图片，优先使用RSS项目的封面，没有时查询Bangumi海报
+// 没有渠道需要图片时不查询
+func (bm *BangumiMonitor) releaseImage(ctx context.Context, item *FeedItem, release *Release) string {
+	if bm.posters == nil {
+		return ""
+	}
+	if item != nil {
+		if image := itemImage(*item); image != "" {
+			return image
+		}
+	}
+	if release == nil || release.Series == "" {
+		return ""
+	}
+
+	poster, err := bm.posters.Poster(ctx, release.Series)
+	if err != nil {
+		log.Printf("⚠️  获取番剧海报失败: %v", err)
+		return ""
+	}
+	return poster
+}
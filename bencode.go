@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// bencodeMaxDepth 列表和字典的最大嵌套层数，避免恶意数据耗尽栈空间
+const bencodeMaxDepth = 64
+
+// bencodeDecoder 简单的bencode解码器
+// 整数解码为int64，字符串为string，列表为[]interface{}，字典为map[string]interface{}
+type bencodeDecoder struct {
+	data  []byte
+	pos   int
+	depth int // 当前的嵌套层数
+}
+
+// decodeBencodeDict 解码一个完整的bencode字典，字典之后不能有多余内容
+// raw 不为nil时，会记录每个键对应值的原始字节
+func decodeBencodeDict(data []byte, raw map[string][]byte) (map[string]interface{}, error) {
+	d := &bencodeDecoder{data: data}
+
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+	if c != 'd' {
+		return nil, fmt.Errorf("bencode数据不是字典")
+	}
+
+	dict, err := d.decodeDict(raw)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("bencode字典之后有多余内容 (位置 %d)", d.pos)
+	}
+	return dict, nil
+}
+
+// peek 返回当前位置的字节
+func (d *bencodeDecoder) peek() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, fmt.Errorf("bencode数据意外结束 (位置 %d)", d.pos)
+	}
+	return d.data[d.pos], nil
+}
+
+// decode 解码当前位置的一个值
+func (d *bencodeDecoder) decode() (interface{}, error) {
+	c, err := d.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c == 'i':
+		return d.decodeInt()
+	case c == 'l':
+		return d.decodeList()
+	case c == 'd':
+		return d.decodeDict(nil)
+	case c >= '0' && c <= '9':
+		return d.decodeString()
+	}
+
+	return nil, fmt.Errorf("无效的bencode类型 '%c' (位置 %d)", c, d.pos)
+}
+
+// enter 进入一层列表或字典，超过 bencodeMaxDepth 时返回错误，返回的函数用于退出这一层
+func (d *bencodeDecoder) enter() (func(), error) {
+	if d.depth >= bencodeMaxDepth {
+		return nil, fmt.Errorf("bencode嵌套超过 %d 层 (位置 %d)", bencodeMaxDepth, d.pos)
+	}
+	d.depth++
+	return func() { d.depth-- }, nil
+}
+
+// decodeInt 解码整数 i<数字>e
+func (d *bencodeDecoder) decodeInt() (int64, error) {
+	start := d.pos + 1
+	end := start
+	for end < len(d.data) && d.data[end] != 'e' {
+		end++
+	}
+	if end >= len(d.data) {
+		return 0, fmt.Errorf("bencode整数未结束 (位置 %d)", d.pos)
+	}
+
+	n, err := strconv.ParseInt(string(d.data[start:end]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效的bencode整数 (位置 %d): %v", d.pos, err)
+	}
+
+	d.pos = end + 1
+	return n, nil
+}
+
+// decodeString 解码字符串 <长度>:<内容>
+func (d *bencodeDecoder) decodeString() (string, error) {
+	colon := d.pos
+	for colon < len(d.data) && d.data[colon] != ':' {
+		colon++
+	}
+	if colon >= len(d.data) {
+		return "", fmt.Errorf("bencode字符串缺少长度分隔符 (位置 %d)", d.pos)
+	}
+
+	length, err := strconv.Atoi(string(d.data[d.pos:colon]))
+	if err != nil || length < 0 {
+		return "", fmt.Errorf("无效的bencode字符串长度 (位置 %d)", d.pos)
+	}
+
+	start := colon + 1
+	if length > len(d.data)-start {
+		return "", fmt.Errorf("bencode字符串超出数据范围 (位置 %d)", d.pos)
+	}
+
+	d.pos = start + length
+	return string(d.data[start:d.pos]), nil
+}
+
+// decodeList 解码列表 l...e
+func (d *bencodeDecoder) decodeList() ([]interface{}, error) {
+	leave, err := d.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	d.pos++
+
+	var list []interface{}
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == 'e' {
+			d.pos++
+			return list, nil
+		}
+
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+}
+
+// decodeDict 解码字典 d...e
+// raw 不为nil时，会记录每个键对应值的原始字节，用于计算info字典的infohash
+func (d *bencodeDecoder) decodeDict(raw map[string][]byte) (map[string]interface{}, error) {
+	leave, err := d.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer leave()
+	d.pos++
+
+	dict := make(map[string]interface{})
+	for {
+		c, err := d.peek()
+		if err != nil {
+			return nil, err
+		}
+		if c == 'e' {
+			d.pos++
+			return dict, nil
+		}
+
+		key, err := d.decodeString()
+		if err != nil {
+			return nil, fmt.Errorf("无效的bencode字典键: %v", err)
+		}
+
+		start := d.pos
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+
+		dict[key] = value
+		if raw != nil {
+			raw[key] = d.data[start:d.pos]
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTorrentFile(t *testing.T) {
+	data, err := os.ReadFile("testdata/frieren-12.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := parseTorrentFile(data)
+	if err != nil {
+		t.Fatalf("parseTorrentFile: %v", err)
+	}
+
+	want := &TorrentMeta{
+		InfoHash: "16bd43a0d9d2df9a3ddfe93d197ee6b3a544f839",
+		Name:     "[LoliHouse] Sousou no Frieren - 12 [WebRip 1080p HEVC-10bit AAC].mkv",
+		Length:   649487360,
+		Trackers: []string{"http://tracker.example.org/announce", "udp://tracker.example.net:6969/announce"},
+	}
+	if !reflect.DeepEqual(meta, want) {
+		t.Errorf("got  %+v\nwant %+v", meta, want)
+	}
+
+	// 种子文件之后附带的内容不能被忽略
+	if _, err := parseTorrentFile(append(data, "garbage"...)); err == nil {
+		t.Error("torrent with trailing bytes parsed, want error")
+	}
+}
+
+func TestDecodeBencodeDict(t *testing.T) {
+	raw := make(map[string][]byte)
+	dict, err := decodeBencodeDict([]byte("d1:ai-42e1:bl3:fooi0ee1:cd1:x0:ee"), raw)
+	if err != nil {
+		t.Fatalf("decodeBencodeDict: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"a": int64(-42),
+		"b": []interface{}{"foo", int64(0)},
+		"c": map[string]interface{}{"x": ""},
+	}
+	if !reflect.DeepEqual(dict, want) {
+		t.Errorf("got %#v, want %#v", dict, want)
+	}
+	if string(raw["c"]) != "d1:x0:e" {
+		t.Errorf("raw[c] = %q, want %q", raw["c"], "d1:x0:e")
+	}
+}
+
+// nested 构造嵌套 depth 层的bencode数据，最外层是字典
+func nested(depth int) string {
+	return "d1:a" + strings.Repeat("l", depth-1) + strings.Repeat("e", depth-1) + "e"
+}
+
+func TestDecodeBencodeDictErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string // 为空时应解码成功
+	}{
+		{"max depth", nested(bencodeMaxDepth), ""},
+		{"too deep", nested(bencodeMaxDepth + 1), "嵌套超过"},
+		{"trailing bytes", "d1:ai1ee1:x", "多余内容"},
+		{"trailing dict", "d1:ai1eede", "多余内容"},
+		{"not a dict", "l1:ae", "不是字典"},
+		{"empty", "", "意外结束"},
+		{"unterminated dict", "d1:ai1e", "意外结束"},
+		{"truncated string", "d1:a5:abce", "超出数据范围"},
+		{"missing colon", "d1:a5", "缺少长度分隔符"},
+		{"length overflow", "d1:a99999999999999999999:xe", "无效的bencode字符串长度"},
+		{"length past end", "d1:a9223372036854775807:xe", "超出数据范围"},
+		{"negative length", "d-1:xi1ee", "无效的bencode字符串长度"},
+		{"unterminated int", "d1:ai12", "整数未结束"},
+		{"invalid int", "d1:ai1x2ee", "无效的bencode整数"},
+		{"invalid type", "d1:ax", "无效的bencode类型"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeBencodeDict([]byte(tt.data), nil)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("decodeBencodeDict: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want containing %q", err, tt.err)
+			}
+		})
+	}
+}
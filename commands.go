@@ -0,0 +1,506 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxListedTasks /tasks 最多列出的任务数量
+const maxListedTasks = 20
+
+// Command 从聊天中收到的命令，与具体的聊天软件无关
+type Command struct {
+	Name string   // 命令名，不含斜杠，小写
+	Args []string // 参数
+	From string   // 发送者，用于日志
+}
+
+// 通知消息上按钮对应的操作
+const (
+	ActionCancel = "cancel" // 取消下载，删除任务和已下载的文件
+	ActionRetry  = "retry"  // 重试任务
+	ActionMute   = "mute"   // 屏蔽该番剧，之后不再下载
+)
+
+// CommandHandler 处理聊天命令和通知消息上的按钮
+type CommandHandler interface {
+	// HandleCommand 执行命令，返回回复内容
+	HandleCommand(ctx context.Context, cmd *Command) string
+	// HandleAction 对任务执行按钮操作，返回操作后的状态
+	HandleAction(ctx context.Context, action, taskID string) (string, error)
+}
+
+// CommandListener 可以接收聊天命令的通知渠道
+type CommandListener interface {
+	// Listen 持续接收命令并交给 handler 处理，直到ctx被取消
+	Listen(ctx context.Context, handler CommandHandler)
+}
+
+// commandHelp 命令说明
+const commandHelp = `📖 可用命令
+/subs - 查看订阅
+/sub <RSS地址> [名称] - 添加订阅
+/unsub <序号或名称> - 删除订阅
+/unmute <番剧名> - 取消屏蔽番剧
+/tasks - 查看下载任务
+/retry <任务ID> - 重试任务
+/rm <任务ID> - 删除任务（保留已下载的文件）
+/add <磁力链接> - 添加下载
+/quota - 查看PikPak存储空间
+/check - 立即检查RSS和任务状态`
+
+// commandAliases 中文命令 -> 命令名，用于不支持斜杠命令的聊天软件（如QQ）
+var commandAliases = map[string]string{
+	"订阅":   "subs", // 带参数时为添加订阅
+	"取消订阅": "unsub",
+	"取消屏蔽": "unmute",
+	"任务列表": "tasks",
+	"任务":   "tasks",
+	"重试":   "retry",
+	"删除任务": "rm",
+	"磁力":   "add",
+	"空间":   "quota",
+	"检查":   "check",
+	"帮助":   "help",
+}
+
+// parseCommandText 从文本消息中解析命令，支持 /命令 参数、中文命令和直接发送的磁力链接
+func parseCommandText(text string) (*Command, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	name, args := fields[0], fields[1:]
+	switch {
+	case strings.HasPrefix(name, "/"):
+		name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	case strings.HasPrefix(name, "magnet:?"):
+		name, args = "add", fields
+	default:
+		alias, ok := commandAliases[name]
+		if !ok {
+			return nil, false
+		}
+		name = alias
+		if name == "subs" && len(args) > 0 {
+			name = "sub"
+		}
+	}
+
+	return &Command{Name: name, Args: args}, true
+}
+
+// HandleCommand 执行命令
+func (bm *BangumiMonitor) HandleCommand(ctx context.Context, cmd *Command) string {
+	log.Printf("💬 收到命令: /%s %s (来自: %s)", cmd.Name, strings.Join(cmd.Args, " "), cmd.From)
+
+	switch cmd.Name {
+	case "subs":
+		return bm.commandSubs()
+	case "sub":
+		return bm.commandSub(ctx, cmd.Args)
+	case "unsub":
+		return bm.commandUnsub(cmd.Args)
+	case "unmute":
+		return bm.commandUnmute(cmd.Args)
+	case "tasks":
+		return bm.commandTasks()
+	case "retry":
+		return bm.commandRetry(cmd.Args)
+	case "rm":
+		return bm.commandRemove(cmd.Args)
+	case "add":
+		return bm.commandAdd(cmd.Args)
+	case "quota":
+		return bm.commandQuota()
+	case "check":
+		return bm.commandCheck(ctx)
+	case "start", "help":
+		return commandHelp
+	}
+	return fmt.Sprintf("❓ 未知命令: %s\n\n%s", cmd.Name, commandHelp)
+}
+
+// commandSubs 列出所有订阅
+func (bm *BangumiMonitor) commandSubs() string {
+	subscriptions := bm.subscriptionList()
+	if len(subscriptions) == 0 {
+		return "📭 没有订阅"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📡 订阅列表 (%d)\n", len(subscriptions))
+	for i, sub := range subscriptions {
+		fmt.Fprintf(&sb, "\n%d. %s", i+1, sub.Name)
+		if sub.Downloader != "" {
+			fmt.Fprintf(&sb, " [%s]", sub.Downloader)
+		}
+		fmt.Fprintf(&sb, "\n   %s", sub.URL)
+		if muted := bm.mutedSeries(sub.Name); len(muted) > 0 {
+			fmt.Fprintf(&sb, "\n   🔇 %s", strings.Join(muted, ", "))
+		}
+	}
+	return sb.String()
+}
+
+// commandSub 添加订阅，现有的项目全部标记为已见，只下载之后发布的内容
+func (bm *BangumiMonitor) commandSub(ctx context.Context, args []string) string {
+	if len(args) == 0 {
+		return "用法: /sub <RSS地址> [名称]"
+	}
+
+	feedURL := args[0]
+	if u, err := url.Parse(feedURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Sprintf("❌ 无效的RSS地址: %s", feedURL)
+	}
+
+	feed, err := bm.fetchFeed(ctx, feedURL)
+	if err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+
+	name := strings.Join(args[1:], " ")
+	if name == "" {
+		name = strings.TrimSpace(feed.Title)
+	}
+	if name == "" {
+		name = feedURL
+	}
+
+	// 未配置PikPak时与 /add 一样使用第一个下载器
+	sub := &Subscription{Name: name, URL: feedURL, Enabled: true}
+	if _, err := bm.downloaderFor(sub); err != nil {
+		names := bm.downloaderNames()
+		if len(names) == 0 {
+			return "❌ 没有配置下载器"
+		}
+		sub.Downloader = names[0]
+	}
+
+	// 与定期检查互斥，避免检查在标记已见之前就下载了新订阅的现有项目
+	bm.checkMutex.Lock()
+	defer bm.checkMutex.Unlock()
+
+	if err := bm.addSubscription(sub); err != nil {
+		return fmt.Sprintf("❌ 添加订阅失败: %v", err)
+	}
+
+	count, err := bm.initializeFeed(ctx, sub)
+	if err != nil {
+		log.Printf("❌ 初始化RSS源失败: %v", err)
+	}
+
+	log.Printf("➕ 已添加订阅: %s (%s)", sub.Name, sub.URL)
+	return fmt.Sprintf("✅ 已添加订阅: %s\n📋 已将现有的 %d 个项目标记为已见，之后发布的内容会自动下载", sub.Name, count)
+}
+
+// commandUnsub 按序号或名称删除订阅
+func (bm *BangumiMonitor) commandUnsub(args []string) string {
+	if len(args) == 0 {
+		return "用法: /unsub <序号或名称>"
+	}
+
+	name := strings.Join(args, " ")
+	subscriptions := bm.subscriptionList()
+	if index, err := strconv.Atoi(name); err == nil {
+		if index < 1 || index > len(subscriptions) {
+			return fmt.Sprintf("❌ 序号超出范围: %d", index)
+		}
+		name = subscriptions[index-1].Name
+	}
+
+	if err := bm.removeSubscription(name); err != nil {
+		return fmt.Sprintf("❌ 删除订阅失败: %v", err)
+	}
+
+	log.Printf("➖ 已删除订阅: %s", name)
+	return fmt.Sprintf("✅ 已删除订阅: %s", name)
+}
+
+// commandUnmute 取消屏蔽番剧
+func (bm *BangumiMonitor) commandUnmute(args []string) string {
+	if len(args) == 0 {
+		return "用法: /unmute <番剧名>"
+	}
+
+	series := strings.Join(args, " ")
+	subs, err := bm.unmuteSeries(series)
+	if err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+
+	log.Printf("🔊 已取消屏蔽番剧: %s (订阅: %s)", series, strings.Join(subs, ", "))
+	return fmt.Sprintf("🔊 已取消屏蔽: %s", series)
+}
+
+// commandTasks 列出各下载器中未完成的任务，以及最近完成的任务
+func (bm *BangumiMonitor) commandTasks() string {
+	var sb strings.Builder
+	for _, name := range bm.downloaderNames() {
+		tasks, err := bm.downloaders[name].ListTasks()
+		if err != nil {
+			fmt.Fprintf(&sb, "⬇️ %s\n❌ %v\n\n", name, err)
+			continue
+		}
+
+		// 未完成的任务在前，同类按创建时间倒序
+		sort.SliceStable(tasks, func(i, j int) bool {
+			doneI, doneJ := tasks[i].Phase == TaskPhaseComplete, tasks[j].Phase == TaskPhaseComplete
+			if doneI != doneJ {
+				return !doneI
+			}
+			return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+		})
+
+		fmt.Fprintf(&sb, "⬇️ %s (%d)\n", name, len(tasks))
+		for i, task := range tasks {
+			if i == maxListedTasks {
+				fmt.Fprintf(&sb, "... 还有 %d 个任务\n", len(tasks)-maxListedTasks)
+				break
+			}
+			fmt.Fprintf(&sb, "%s %s (%d%%)\n   ID: %s\n", taskPhaseIcon(task.Phase), task.Name, task.Progress, task.ID)
+		}
+		sb.WriteString("\n")
+	}
+
+	if sb.Len() == 0 {
+		return "📭 没有配置下载器"
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// taskPhaseIcon 任务状态对应的图标
+func taskPhaseIcon(phase string) string {
+	switch phase {
+	case TaskPhaseComplete:
+		return "✅"
+	case TaskPhaseError:
+		return "❌"
+	case TaskPhasePending:
+		return "🕒"
+	}
+	return "⏳"
+}
+
+// downloaderNames 按名称排序的下载器列表，pikpak 在最前
+func (bm *BangumiMonitor) downloaderNames() []string {
+	names := make([]string, 0, len(bm.downloaders))
+	for name := range bm.downloaders {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == pikpakDownloaderName) != (names[j] == pikpakDownloaderName) {
+			return names[i] == pikpakDownloaderName
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// findTaskDownloader 找到任务所在的下载器，优先使用跟踪记录
+func (bm *BangumiMonitor) findTaskDownloader(taskID string) (Downloader, error) {
+	for _, task := range bm.tracker.Tasks() {
+		if task.ID == taskID {
+			if d, ok := bm.downloaders[task.downloaderName()]; ok {
+				return d, nil
+			}
+		}
+	}
+
+	for _, name := range bm.downloaderNames() {
+		if _, err := bm.downloaders[name].GetTask(taskID); err == nil {
+			return bm.downloaders[name], nil
+		}
+	}
+	return nil, fmt.Errorf("未找到任务: %s", taskID)
+}
+
+// commandRetry 重试任务
+func (bm *BangumiMonitor) commandRetry(args []string) string {
+	if len(args) == 0 {
+		return "用法: /retry <任务ID>"
+	}
+
+	if err := bm.retryTask(args[0]); err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+	return fmt.Sprintf("🔄 已重试任务: %s", args[0])
+}
+
+// commandRemove 删除任务并停止跟踪，保留已下载的文件
+func (bm *BangumiMonitor) commandRemove(args []string) string {
+	if len(args) == 0 {
+		return "用法: /rm <任务ID>"
+	}
+
+	if err := bm.removeTask(args[0], false); err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+	return fmt.Sprintf("🗑️ 已删除任务: %s", args[0])
+}
+
+// retryTask 在任务所在的下载器中重试任务
+func (bm *BangumiMonitor) retryTask(taskID string) error {
+	downloader, err := bm.findTaskDownloader(taskID)
+	if err != nil {
+		return err
+	}
+	return downloader.RetryTask(taskID)
+}
+
+// removeTask 删除任务并停止跟踪
+func (bm *BangumiMonitor) removeTask(taskID string, deleteFiles bool) error {
+	downloader, err := bm.findTaskDownloader(taskID)
+	if err != nil {
+		return err
+	}
+	if err := downloader.RemoveTask(taskID, deleteFiles); err != nil {
+		return err
+	}
+	bm.tracker.Untrack(taskID)
+	return nil
+}
+
+// commandAdd 手动添加磁力链接，使用默认下载器和默认目录
+func (bm *BangumiMonitor) commandAdd(args []string) string {
+	if len(args) == 0 {
+		return "用法: /add <磁力链接>"
+	}
+
+	link := args[0]
+	infoHash, err := parseMagnetInfoHash(link)
+	if err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+	if existing, ok := bm.store.SeenInfoHash(infoHash); ok && existing.TaskID != "" {
+		return fmt.Sprintf("🔁 该种子已经下载过: %s", existing.Title)
+	}
+
+	names := bm.downloaderNames()
+	if len(names) == 0 {
+		return "❌ 没有配置下载器"
+	}
+	downloader := bm.downloaders[names[0]]
+
+	title := infoHash
+	if u, err := url.Parse(link); err == nil && u.Query().Get("dn") != "" {
+		title = u.Query().Get("dn")
+	}
+	fileName := bm.cleanFileName(title)
+
+	folderID, err := downloader.ResolveFolder("")
+	if err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+	taskID, err := downloader.AddTask(fileName, link, folderID)
+	if err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+
+	// 记录infohash，RSS中再出现同一个种子时不会重复下载
+	submittedAt := time.Now()
+	if err := bm.store.Add(SeenRecord{
+		GUID:        link,
+		InfoHash:    infoHash,
+		Title:       title,
+		SubmittedAt: submittedAt,
+		TaskID:      taskID,
+	}); err != nil {
+		log.Printf("❌ 保存已见项目失败: %v", err)
+	}
+
+	bm.tracker.Track(&TrackedTask{
+		ID:          taskID,
+		FileName:    fileName,
+		Title:       title,
+		Downloader:  downloader.Name(),
+		InfoHash:    infoHash,
+		FolderID:    folderID,
+		SubmittedAt: submittedAt,
+	})
+
+	return fmt.Sprintf("✅ 已添加下载: %s\n⬇️ 下载器: %s\n📋 任务ID: %s", title, downloader.Name(), taskID)
+}
+
+// commandQuota 查看PikPak存储空间
+func (bm *BangumiMonitor) commandQuota() string {
+	if bm.downloader == nil {
+		return "❌ 没有配置PikPak"
+	}
+
+	usage, limit, err := bm.downloader.Quota()
+	if err != nil {
+		return fmt.Sprintf("❌ %v", err)
+	}
+
+	return fmt.Sprintf("💾 PikPak存储空间\n📊 已用: %s / %s (%d%%)", formatSize(usage), formatSize(limit), quotaPercent(usage, limit))
+}
+
+// commandCheck 立即检查所有RSS源和任务状态
+func (bm *BangumiMonitor) commandCheck(ctx context.Context) string {
+	log.Printf("⏰ 手动触发检查: %s", time.Now().Format("2006-01-02 15:04:05"))
+	bm.checkAllSources(ctx)
+	bm.tracker.Poll()
+	return fmt.Sprintf("✅ 检查完成，跟踪中的任务: %d 个", len(bm.tracker.Tasks()))
+}
+
+// HandleAction 执行通知消息上的按钮操作
+func (bm *BangumiMonitor) HandleAction(ctx context.Context, action, taskID string) (string, error) {
+	log.Printf("🔘 收到按钮操作: %s (任务: %s)", action, taskID)
+
+	switch action {
+	case ActionCancel:
+		if err := bm.removeTask(taskID, true); err != nil {
+			return "", err
+		}
+		return "🗑️ 已取消下载", nil
+	case ActionRetry:
+		if err := bm.retryTask(taskID); err != nil {
+			return "", err
+		}
+		return "🔄 已重试", nil
+	case ActionMute:
+		subName, series, err := bm.taskSeries(taskID)
+		if err != nil {
+			return "", err
+		}
+		if err := bm.muteSeries(subName, series); err != nil {
+			return "", err
+		}
+		log.Printf("🔇 已屏蔽番剧: %s (订阅: %s)", series, subName)
+		return fmt.Sprintf("🔇 已屏蔽: %s", series), nil
+	}
+	return "", fmt.Errorf("未知操作: %s", action)
+}
+
+// taskSeries 找到任务所属的订阅和番剧名，任务结束后从已见项目记录中查找
+func (bm *BangumiMonitor) taskSeries(taskID string) (string, string, error) {
+	subName, title := "", ""
+	for _, task := range bm.tracker.Tasks() {
+		if task.ID == taskID {
+			subName, title = task.Subscription, task.Title
+			break
+		}
+	}
+	if title == "" {
+		if record, ok := bm.store.SeenTask(taskID); ok {
+			subName, title = record.Subscription, record.Title
+		}
+	}
+
+	if subName == "" {
+		return "", "", fmt.Errorf("任务不属于任何订阅: %s", taskID)
+	}
+	series := ParseRelease(title).Series
+	if series == "" {
+		return "", "", fmt.Errorf("无法识别番剧名: %s", title)
+	}
+	return subName, series, nil
+}
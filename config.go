@@ -1,11 +1,22 @@
 package main
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
 type Config struct {
-	Pikpak struct {
+	DataDir string `json:"data_dir"`
+	Pikpak  struct {
 		Passwd     string `json:"passwd"`
 		User       string `json:"user"`
 		FolderID   string `json:"folder_id"`
 		FolderPath string `json:"folder_path"`
+		// QuotaWarningPercent 存储空间使用率达到该百分比时发送提醒，0 表示不提醒
+		QuotaWarningPercent int `json:"quota_warning_percent"`
 	} `json:"pikpak"`
 	RSS struct {
 		URLs                 []string `json:"urls"`
@@ -14,15 +25,148 @@ type Config struct {
 		ExcludeKeywords      []string `json:"exclude_keywords"`
 		Resolutions          []string `json:"resolutions"`
 	} `json:"rss"`
-	QQ struct {
-		Enabled     bool     `json:"enabled"`
-		BotURL      string   `json:"bot_url"`
-		Token       string   `json:"token"`
-		NotifyUsers []string `json:"notify_users"`
+	Downloaders   []DownloaderConfig `json:"downloaders"`
+	Subscriptions []Subscription     `json:"subscriptions"`
+	Tracker       struct {
+		PollIntervalMinutes int `json:"poll_interval_minutes"`
+		MaxRetries          int `json:"max_retries"`
+		TimeoutHours        int `json:"timeout_hours"`
+	} `json:"tracker"`
+	// Outbox 发送失败的通知的重试设置
+	Outbox struct {
+		RetryDelaySeconds int `json:"retry_delay_seconds"` // 第一次重试前的等待时间，之后每次翻倍
+		MaxDelayMinutes   int `json:"max_delay_minutes"`   // 重试间隔的上限
+		MaxAgeHours       int `json:"max_age_hours"`       // 超过该时间仍未发送成功的通知会被放弃
+	} `json:"outbox"`
+	Notifiers []NotifierConfig  `json:"notifiers"`
+	Templates map[string]string `json:"templates"` // 所有渠道共用的消息模板，事件类型 -> 模板
+	QQ        struct {
+		Enabled      bool     `json:"enabled"`
+		BotURL       string   `json:"bot_url"`
+		Token        string   `json:"token"`
+		NotifyUsers  []string `json:"notify_users"`
+		NotifyGroups []string `json:"notify_groups"`
+		Mentions     []string `json:"mentions"`
+		Images       bool     `json:"images"`
+		Listen       string   `json:"listen"`
+		Secret       string   `json:"secret"`
 	} `json:"qq"`
 	Telegram struct {
-		Enabled bool   `json:"enabled"`
-		Token   string `json:"token"`
-		ChatID  int64  `json:"chat_id"`
+		Enabled      bool    `json:"enabled"`
+		Token        string  `json:"token"`
+		ChatID       int64   `json:"chat_id"`
+		ParseMode    string  `json:"parse_mode"`
+		Commands     bool    `json:"commands"`
+		AllowedChats []int64 `json:"allowed_chats"`
 	} `json:"telegram"`
 }
+
+// Subscription 单个订阅的配置，每个订阅有自己的RSS源、过滤规则和下载目录
+type Subscription struct {
+	Name            string   `json:"name"`
+	URL             string   `json:"url"`
+	Enabled         bool     `json:"enabled"`
+	Keywords        []string `json:"keywords"`
+	ExcludeKeywords []string `json:"exclude_keywords"`
+	Resolutions     []string `json:"resolutions"`
+	Groups          []string `json:"groups"`
+	Downloader      string   `json:"downloader"` // 下载器名称，默认 pikpak
+	FolderID        string   `json:"folder_id"`
+	FolderPath      string   `json:"folder_path"`
+	Organize        struct {
+		Enabled        bool   `json:"enabled"`
+		RootPath       string `json:"root_path"`
+		SeriesName     string `json:"series_name"`
+		Season         int    `json:"season"`
+		FolderTemplate string `json:"folder_template"`
+		NameTemplate   string `json:"name_template"`
+	} `json:"organize"`
+
+	includeRules []*Rule // 由 Keywords 编译
+	excludeRules []*Rule // 由 ExcludeKeywords 编译
+	folderTmpl   *template.Template
+	nameTmpl     *template.Template
+}
+
+// DownloaderConfig 本地下载器的配置，订阅通过 name 选择下载器
+type DownloaderConfig struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"` // qbittorrent / transmission / aria2
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Token    string `json:"token"`     // aria2 的 rpc-secret
+	SavePath string `json:"save_path"` // 默认保存目录
+	Category string `json:"category"`  // qBittorrent 分类
+}
+
+// NotifierConfig 通知渠道的配置，这里只有所有渠道共用的字段
+// 其他字段保存在 Options 中，由各渠道的构造函数解析到自己的配置结构体，未知的字段会报错
+type NotifierConfig struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`   // qq / telegram / webhook / bark / serverchan / ntfy / gotify / pushplus / wecom / dingtalk / feishu / discord / slack / email
+	Events []string `json:"events"` // 接收的事件类型，为空时接收所有事件
+	// BatchSeconds 批量发送窗口（秒），第一个事件到达后等待这么久，期间的事件合并成一条汇总消息
+	BatchSeconds int `json:"batch_seconds"`
+	// RateLimit 每分钟最多发送的消息数，超出时积压的事件合并成一条汇总消息，0 表示不限速
+	RateLimit int `json:"rate_limit"`
+	Burst     int `json:"burst"` // 最多连续发送的消息数，默认等于 rate_limit
+	// QuietHours 免打扰时段，如 23:00-08:00，期间的事件暂存，结束后合并发送
+	QuietHours string `json:"quiet_hours"`
+	// Templates 该渠道的消息模板，事件类型 -> 模板，优先于全局模板
+	Templates map[string]string `json:"templates"`
+	// Options 渠道自己的配置项（上面以外的所有字段）
+	Options json.RawMessage `json:"-"`
+	// DataDir 数据目录，由全局配置的 data_dir 填入，用于需要保存状态的渠道
+	DataDir string `json:"-"`
+}
+
+// notifierCommonKeys NotifierConfig 中所有渠道共用的字段，不放入 Options
+var notifierCommonKeys = []string{"name", "type", "events", "batch_seconds", "rate_limit", "burst", "quiet_hours", "templates"}
+
+// UnmarshalJSON 解析共用字段，其余字段保存到 Options
+func (nc *NotifierConfig) UnmarshalJSON(data []byte) error {
+	type plain NotifierConfig
+	if err := json.Unmarshal(data, (*plain)(nc)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, key := range notifierCommonKeys {
+		delete(fields, key)
+	}
+
+	options, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	nc.Options = options
+	return nil
+}
+
+// decodeOptions 将渠道自己的配置项解析到 options，有该渠道不支持的字段时返回错误
+func (nc NotifierConfig) decodeOptions(options interface{}) error {
+	if len(nc.Options) == 0 {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(nc.Options))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(options); err != nil {
+		// 拼错或放错渠道的字段，如给Bark配置了 priority
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("不支持的配置项 %s", field)
+		}
+		return fmt.Errorf("配置无效: %s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return nil
+}
+
+// notifierOptions 将配置结构体编码为 Options，用于由旧配置块生成的渠道
+func notifierOptions(options interface{}) json.RawMessage {
+	data, _ := json.Marshal(options)
+	return data
+}
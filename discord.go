@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// discordColors Discord嵌入内容左侧的颜色，按事件类型区分
+var discordColors = map[string]int{
+	EventNewRelease:   0x3498DB, // 蓝
+	EventTaskComplete: 0x2ECC71, // 绿
+	EventTaskFailed:   0xE74C3C, // 红
+	EventFeedError:    0xE67E22, // 橙
+	EventQuotaWarning: 0xF1C40F, // 黄
+	EventSummary:      0x95A5A6, // 灰
+}
+
+// discordEmbed Discord的嵌入内容
+type discordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description,omitempty"`
+	Color       int                 `json:"color"`
+	Fields      []discordEmbedField `json:"fields,omitempty"`
+	Thumbnail   *discordEmbedImage  `json:"thumbnail,omitempty"`
+	Timestamp   string              `json:"timestamp"`
+}
+
+type discordEmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordEmbedImage struct {
+	URL string `json:"url"`
+}
+
+// DiscordOptions Discord渠道的配置
+type DiscordOptions struct {
+	URL     string `json:"url"`      // 频道的Webhook地址
+	Images  bool   `json:"images"`   // 是否把封面作为缩略图
+	IconURL string `json:"icon_url"` // Webhook的头像
+}
+
+// DiscordNotifier 通过Discord Webhook发送嵌入消息，url 为频道的Webhook地址
+type DiscordNotifier struct {
+	name     string
+	webhook  string
+	images   bool
+	avatar   string
+	renderer *MessageRenderer
+}
+
+// newDiscordNotifier 根据通知渠道配置创建Discord通知
+func newDiscordNotifier(config NotifierConfig) (Notifier, error) {
+	var options DiscordOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.URL == "" {
+		return nil, fmt.Errorf("缺少url（Webhook地址）")
+	}
+
+	renderer, err := NewMessageRenderer(formatCommonMark, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiscordNotifier{name: config.Name, webhook: options.URL, images: options.Images, avatar: options.IconURL, renderer: renderer}, nil
+}
+
+// Name 渠道名称
+func (dn *DiscordNotifier) Name() string {
+	return dn.name
+}
+
+// WantsImages 是否在消息中附带图片
+func (dn *DiscordNotifier) WantsImages() bool {
+	return dn.images
+}
+
+// Send 发送嵌入消息：模板第一行作为标题，其余作为描述，字幕组、集数、分辨率作为字段，封面作为缩略图
+func (dn *DiscordNotifier) Send(event *Event) error {
+	title, body := splitTitle(dn.renderer.Render(event))
+
+	embed := discordEmbed{
+		Title:       truncateRunes(plainMarkdown(title), 256),
+		Description: truncateRunes(body, 4096),
+		Color:       discordColors[event.Type],
+		Timestamp:   event.Time.Format(time.RFC3339),
+	}
+	for _, field := range releaseFields(event) {
+		embed.Fields = append(embed.Fields, discordEmbedField{Name: field.Name, Value: field.Value, Inline: true})
+	}
+	if dn.images && event.ImageURL != "" {
+		embed.Thumbnail = &discordEmbedImage{URL: event.ImageURL}
+	}
+
+	payload := map[string]interface{}{"embeds": []discordEmbed{embed}}
+	if dn.avatar != "" {
+		payload["avatar_url"] = dn.avatar
+	}
+	return postJSON(dn.webhook, payload, nil)
+}
+
+// truncateRunes 按字符截断过长的文本
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
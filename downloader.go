@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// 统一的任务状态
+const (
+	TaskPhasePending  = "pending"
+	TaskPhaseRunning  = "running"
+	TaskPhaseComplete = "complete"
+	TaskPhaseError    = "error"
+)
+
+// pikpakDownloaderName PikPak后端的固定名称
+const pikpakDownloaderName = "pikpak"
+
+// DownloadTask 各下载后端统一的任务信息
+type DownloadTask struct {
+	ID        string
+	Name      string
+	Phase     string // TaskPhase*
+	Progress  int    // 0-100
+	FileID    string // PikPak的文件ID，本地后端为保存路径
+	FileSize  int64
+	Message   string // 出错原因或状态描述
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Downloader 下载后端
+type Downloader interface {
+	// Name 后端名称，对应订阅中的 downloader 字段
+	Name() string
+	// ResolveFolder 将订阅中的目录路径转换为后端使用的位置（PikPak为文件夹ID，本地后端为保存路径）
+	ResolveFolder(folderPath string) (string, error)
+	// AddTask 添加磁力链接或种子链接，返回任务ID
+	AddTask(name, link, folder string) (string, error)
+	// GetTask 查询单个任务
+	GetTask(taskID string) (*DownloadTask, error)
+	// ListTasks 列出所有任务
+	ListTasks() ([]*DownloadTask, error)
+	// RemoveTask 删除任务
+	RemoveTask(taskID string, deleteFiles bool) error
+	// RetryTask 重试失败的任务
+	RetryTask(taskID string) error
+}
+
+// newDownloaders 根据配置创建所有下载后端，pikpak 为空时表示未配置PikPak
+func newDownloaders(config *Config, pikpak *OfflineDownloader) (map[string]Downloader, error) {
+	downloaders := make(map[string]Downloader)
+	if pikpak != nil {
+		downloaders[pikpakDownloaderName] = pikpak
+	}
+
+	for i, dc := range config.Downloaders {
+		if dc.Name == "" {
+			return nil, fmt.Errorf("第 %d 个下载器缺少name", i+1)
+		}
+		if _, ok := downloaders[dc.Name]; ok || dc.Name == pikpakDownloaderName {
+			return nil, fmt.Errorf("下载器名称重复: %s", dc.Name)
+		}
+
+		var d Downloader
+		switch strings.ToLower(dc.Type) {
+		case "qbittorrent", "qbit", "qb":
+			d = NewQBittorrentDownloader(dc)
+		case "transmission":
+			d = NewTransmissionDownloader(dc)
+		case "aria2":
+			d = NewAria2Downloader(dc)
+		default:
+			return nil, fmt.Errorf("下载器 [%s] 的类型不支持: %s", dc.Name, dc.Type)
+		}
+
+		downloaders[dc.Name] = d
+		log.Printf("✅ 已配置下载器: %s (%s)", dc.Name, dc.Type)
+	}
+
+	return downloaders, nil
+}
+
+// downloaderFor 获取订阅使用的下载后端
+func (bm *BangumiMonitor) downloaderFor(sub *Subscription) (Downloader, error) {
+	name := sub.Downloader
+	if name == "" {
+		name = pikpakDownloaderName
+	}
+
+	d, ok := bm.downloaders[name]
+	if !ok {
+		return nil, fmt.Errorf("订阅 [%s] 的下载器未配置: %s", sub.Name, name)
+	}
+	return d, nil
+}
+
+// findTaskIn 在任务列表中查找指定任务
+func findTaskIn(tasks []*DownloadTask, taskID string) (*DownloadTask, error) {
+	for _, task := range tasks {
+		if task.ID == taskID {
+			return task, nil
+		}
+	}
+	return nil, fmt.Errorf("未找到任务: %s", taskID)
+}
+
+// linkInfoHash 获取磁力链接或种子地址中的infohash，本地后端以infohash作为任务ID
+func linkInfoHash(link string) (string, error) {
+	if strings.HasPrefix(link, "magnet:") {
+		return parseMagnetInfoHash(link)
+	}
+	if hash := infoHashFromURL(link); hash != "" {
+		return hash, nil
+	}
+	return "", fmt.Errorf("无法从链接中确定infohash: %s", link)
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 邮件的加密方式
+const (
+	emailStartTLS = "starttls" // 明文连接后升级为TLS，默认端口587
+	emailTLS      = "tls"      // 隐式TLS（SMTPS），默认端口465
+	emailNone     = "none"     // 不加密，只用于本机或内网的邮件中继，默认端口25
+)
+
+// emailDefaultPorts 各加密方式的默认端口
+var emailDefaultPorts = map[string]int{emailStartTLS: 587, emailTLS: 465, emailNone: 25}
+
+// emailDigestEvents 汇总模式下放入汇总的事件，按汇总邮件中的顺序排列，其他事件仍然立即发送
+var emailDigestEvents = []struct {
+	Type  string
+	Title string
+}{
+	{EventNewRelease, "🎬 新番剧"},
+	{EventTaskComplete, "✅ 下载完成"},
+	{EventTaskFailed, "❌ 下载失败"},
+}
+
+// 等待汇总的事件的限制，汇总邮件一直发送失败时丢弃最早的事件
+const (
+	emailDigestMaxEvents = 500
+	emailDigestMaxAge    = 7 * 24 * time.Hour
+)
+
+// htmlTagRegex HTML标签，用于从HTML正文生成纯文本正文
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// EmailNotifier 通过SMTP发送邮件，正文同时包含HTML和纯文本
+// 配置了 digest_hours 时，新番剧和任务事件先保存到数据目录，每隔 digest_hours 小时合并成一封汇总邮件
+type EmailNotifier struct {
+	name       string
+	addr       string
+	host       string
+	encryption string
+	username   string
+	password   string
+	from       *mail.Address
+	to         []*mail.Address
+	digest     time.Duration
+	tlsConfig  *tls.Config
+	renderer   *MessageRenderer
+
+	pending     []*Event // 等待汇总的事件
+	pendingPath string   // 等待汇总的事件的保存文件，重启后继续汇总
+	mutex       sync.Mutex
+}
+
+// EmailOptions 邮件渠道的配置，from 为空时使用 username
+type EmailOptions struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`     // 默认按 encryption 选择 587 / 465 / 25
+	Username string   `json:"username"` // 为空时不认证
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+	// Encryption 加密方式：starttls（默认）、tls（隐式TLS）或 none
+	Encryption string `json:"encryption"`
+	// DigestHours 汇总间隔（小时），大于0时新番剧和任务事件合并成一封邮件发送
+	DigestHours int `json:"digest_hours"`
+}
+
+// newEmailNotifier 根据通知渠道配置创建邮件通知
+func newEmailNotifier(config NotifierConfig) (Notifier, error) {
+	var options EmailOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.Host == "" {
+		return nil, fmt.Errorf("缺少host（SMTP服务器地址）")
+	}
+	if len(options.To) == 0 {
+		return nil, fmt.Errorf("缺少to（收件人）")
+	}
+
+	fromAddress := options.From
+	if fromAddress == "" {
+		fromAddress = options.Username
+	}
+	from, err := mail.ParseAddress(fromAddress)
+	if err != nil {
+		return nil, fmt.Errorf("from 无效: %v", err)
+	}
+	var to []*mail.Address
+	for _, address := range options.To {
+		addr, err := mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("收件人 %s 无效: %v", address, err)
+		}
+		to = append(to, addr)
+	}
+
+	encryption := strings.ToLower(options.Encryption)
+	if encryption == "" {
+		encryption = emailStartTLS
+	}
+	port, ok := emailDefaultPorts[encryption]
+	if !ok {
+		return nil, fmt.Errorf("encryption 必须是 starttls、tls 或 none: %s", options.Encryption)
+	}
+	if options.Port != 0 {
+		port = options.Port
+	}
+
+	if options.DigestHours < 0 {
+		return nil, fmt.Errorf("digest_hours 不能为负数")
+	}
+
+	// 模板按HTML渲染，纯文本正文由HTML正文去掉标签得到
+	renderer, err := NewMessageRenderer(formatHTML, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	en := &EmailNotifier{
+		name:       config.Name,
+		addr:       net.JoinHostPort(options.Host, strconv.Itoa(port)),
+		host:       options.Host,
+		encryption: encryption,
+		username:   options.Username,
+		password:   options.Password,
+		from:       from,
+		to:         to,
+		digest:     time.Duration(options.DigestHours) * time.Hour,
+		tlsConfig:  &tls.Config{ServerName: options.Host},
+		renderer:   renderer,
+	}
+
+	if en.digest > 0 {
+		dataDir := config.DataDir
+		if dataDir == "" {
+			dataDir = "data"
+		}
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			return nil, fmt.Errorf("创建数据目录失败: %v", err)
+		}
+		en.pendingPath = filepath.Join(dataDir, "email_digest_"+url.PathEscape(config.Name)+".json")
+
+		if err := en.loadPending(); err != nil {
+			return nil, err
+		}
+		if len(en.pending) > 0 {
+			log.Printf("📬 恢复 %d 条等待汇总的事件 [%s]", len(en.pending), en.name)
+		}
+	}
+
+	return en, nil
+}
+
+// Name 渠道名称
+func (en *EmailNotifier) Name() string {
+	return en.name
+}
+
+// Send 发送事件邮件，汇总模式下新番剧和任务事件只加入汇总
+func (en *EmailNotifier) Send(event *Event) error {
+	if en.digest > 0 && isDigestEvent(event.Type) {
+		en.mutex.Lock()
+		defer en.mutex.Unlock()
+		en.pending = append(en.pending, event)
+		en.trimPending()
+		// 事件已加入汇总，保存失败只影响重启后的恢复，不需要重试发送
+		if err := en.savePending(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+		return nil
+	}
+
+	message := en.renderer.Render(event)
+	title, _ := splitTitle(message)
+	return en.sendMail(htmlToText(title), htmlToText(message), emailHTML(message))
+}
+
+// Run 汇总模式下每隔 digest_hours 小时发送一次汇总邮件，程序退出时发送剩余的事件
+func (en *EmailNotifier) Run(ctx context.Context) {
+	if en.digest <= 0 {
+		return
+	}
+
+	log.Printf("📬 邮件汇总已启用 [%s]: 每 %v 发送一次", en.name, en.digest)
+
+	ticker := time.NewTicker(en.digest)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			en.flushDigest()
+			return
+		case <-ticker.C:
+			en.flushDigest()
+		}
+	}
+}
+
+// flushDigest 把等待汇总的事件合并成一封邮件发送，发送失败时保留到下一次
+func (en *EmailNotifier) flushDigest() {
+	en.mutex.Lock()
+	events := append([]*Event(nil), en.pending...)
+	en.mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	subject, text, body := en.renderDigest(events)
+	if err := en.sendMail(subject, text, body); err != nil {
+		log.Printf("❌ 发送汇总邮件失败 [%s]: %v", en.name, err)
+		en.mutex.Lock()
+		en.trimPending()
+		if err := en.savePending(); err != nil {
+			log.Printf("⚠️  %v", err)
+		}
+		en.mutex.Unlock()
+		return
+	}
+	log.Printf("✅ 汇总邮件发送成功 [%s]: %d 条事件", en.name, len(events))
+
+	// 发送期间新加入的事件留到下一次
+	sent := make(map[*Event]bool, len(events))
+	for _, event := range events {
+		sent[event] = true
+	}
+
+	en.mutex.Lock()
+	defer en.mutex.Unlock()
+	var remaining []*Event
+	for _, event := range en.pending {
+		if !sent[event] {
+			remaining = append(remaining, event)
+		}
+	}
+	en.pending = remaining
+	if err := en.savePending(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+}
+
+// trimPending 丢弃超过 emailDigestMaxAge 的事件，事件过多时丢弃最早的事件，调用方需持有锁
+func (en *EmailNotifier) trimPending() {
+	cutoff := time.Now().Add(-emailDigestMaxAge)
+	kept := en.pending[:0]
+	for _, event := range en.pending {
+		if event.Time.IsZero() || event.Time.After(cutoff) {
+			kept = append(kept, event)
+		}
+	}
+	if len(kept) > emailDigestMaxEvents {
+		kept = kept[len(kept)-emailDigestMaxEvents:]
+	}
+
+	if dropped := len(en.pending) - len(kept); dropped > 0 {
+		log.Printf("🗑️ 丢弃 %d 条过早的汇总事件 [%s]", dropped, en.name)
+	}
+	en.pending = kept
+}
+
+// loadPending 读取上次保存的等待汇总的事件
+func (en *EmailNotifier) loadPending() error {
+	data, err := os.ReadFile(en.pendingPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取汇总事件文件失败: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &en.pending); err != nil {
+		return fmt.Errorf("解析汇总事件文件失败: %v", err)
+	}
+	en.trimPending()
+	return nil
+}
+
+// savePending 保存等待汇总的事件，调用方需持有锁
+func (en *EmailNotifier) savePending() error {
+	data, err := json.MarshalIndent(en.pending, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码汇总事件失败: %v", err)
+	}
+
+	// 先写临时文件再重命名，避免写到一半被中断
+	tmp := en.pendingPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入汇总事件文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, en.pendingPath); err != nil {
+		return fmt.Errorf("写入汇总事件文件失败: %v", err)
+	}
+	return nil
+}
+
+// renderDigest 渲染汇总邮件，事件按类型分组、按时间排序，返回主题、纯文本正文和HTML正文
+func (en *EmailNotifier) renderDigest(events []*Event) (string, string, string) {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+
+	var summary []string
+	var text, body strings.Builder
+	for _, section := range emailDigestEvents {
+		var messages []string
+		for _, event := range events {
+			if event.Type == section.Type {
+				messages = append(messages, en.renderer.Render(event))
+			}
+		}
+		if len(messages) == 0 {
+			continue
+		}
+
+		heading := fmt.Sprintf("%s (%d)", section.Title, len(messages))
+		summary = append(summary, heading)
+
+		fmt.Fprintf(&text, "%s\n%s\n\n", heading, strings.Repeat("=", 20))
+		fmt.Fprintf(&body, "<h2>%s</h2>\n", html.EscapeString(heading))
+		for _, message := range messages {
+			fmt.Fprintf(&text, "%s\n\n", htmlToText(message))
+			fmt.Fprintf(&body, "<p>%s</p>\n<hr>\n", strings.ReplaceAll(message, "\n", "<br>\n"))
+		}
+	}
+
+	subject := "📬 番剧更新汇总: " + strings.Join(summary, "，")
+	return subject, strings.TrimSpace(text.String()), emailHTMLDocument(body.String())
+}
+
+// sendMail 发送一封同时包含纯文本和HTML正文的邮件
+func (en *EmailNotifier) sendMail(subject, text, body string) error {
+	message, err := en.buildMessage(subject, text, body)
+	if err != nil {
+		return err
+	}
+
+	client, err := en.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if en.username != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP服务器不支持认证")
+		}
+		if err := client.Auth(smtp.PlainAuth("", en.username, en.password, en.host)); err != nil {
+			return fmt.Errorf("SMTP认证失败: %v", err)
+		}
+	}
+
+	if err := client.Mail(en.from.Address); err != nil {
+		return fmt.Errorf("设置发件人失败: %v", err)
+	}
+	for _, to := range en.to {
+		if err := client.Rcpt(to.Address); err != nil {
+			return fmt.Errorf("设置收件人 %s 失败: %v", to.Address, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	return client.Quit()
+}
+
+// dial 连接SMTP服务器，按配置使用隐式TLS或STARTTLS
+func (en *EmailNotifier) dial() (*smtp.Client, error) {
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if en.encryption == emailTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", en.addr, en.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", en.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接SMTP服务器失败: %v", err)
+	}
+	// 整个会话的超时，避免服务器无响应时一直等待
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	client, err := smtp.NewClient(conn, en.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("连接SMTP服务器失败: %v", err)
+	}
+
+	if en.encryption == emailStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, fmt.Errorf("SMTP服务器不支持STARTTLS")
+		}
+		if err := client.StartTLS(en.tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS失败: %v", err)
+		}
+	}
+
+	return client, nil
+}
+
+// buildMessage 生成 multipart/alternative 格式的邮件
+func (en *EmailNotifier) buildMessage(subject, text, body string) ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	to := make([]string, len(en.to))
+	for i, addr := range en.to {
+		to[i] = addr.String()
+	}
+
+	headers := []string{
+		"From: " + en.from.String(),
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + mime.BEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"Message-ID: " + emailMessageID(en.host),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", text},
+		{"text/html; charset=utf-8", body},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("生成邮件失败: %v", err)
+		}
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write([]byte(part.content)); err != nil {
+			return nil, fmt.Errorf("生成邮件失败: %v", err)
+		}
+		qw.Close()
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("生成邮件失败: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// isDigestEvent 判断事件是否放入汇总
+func isDigestEvent(eventType string) bool {
+	for _, section := range emailDigestEvents {
+		if section.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// htmlToText 去掉HTML标签并还原转义字符
+func htmlToText(s string) string {
+	return html.UnescapeString(htmlTagRegex.ReplaceAllString(s, ""))
+}
+
+// emailHTML 把单条消息转换为HTML邮件正文
+func emailHTML(message string) string {
+	return emailHTMLDocument("<p>" + strings.ReplaceAll(message, "\n", "<br>\n") + "</p>\n")
+}
+
+// emailHTMLDocument 包装成完整的HTML文档
+func emailHTMLDocument(body string) string {
+	return "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"></head>\n" +
+		"<body style=\"font-family: sans-serif; line-height: 1.6;\">\n" + body + "</body>\n</html>\n"
+}
+
+// emailMessageID 生成邮件的Message-ID
+func emailMessageID(host string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), host)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpMail SMTP服务器收到的邮件
+type smtpMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// smtpStandIn 本地的SMTP服务器，只实现发送邮件需要的命令，不支持认证和加密
+type smtpStandIn struct {
+	listener net.Listener
+	mails    chan smtpMail
+	wg       sync.WaitGroup
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &smtpStandIn{listener: listener, mails: make(chan smtpMail, 4)}
+	server.wg.Add(1)
+	go server.serve()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *smtpStandIn) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *smtpStandIn) port() string {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return port
+}
+
+func (s *smtpStandIn) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	var current smtpMail
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			current = smtpMail{from: strings.Trim(strings.TrimSpace(line)[10:], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			current.to = append(current.to, strings.Trim(strings.TrimSpace(line)[8:], "<>"))
+			reply("250 OK")
+		case command == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			current.data = data.String()
+			s.mails <- current
+			reply("250 OK")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// receive 等待下一封邮件
+func (s *smtpStandIn) receive(t *testing.T) smtpMail {
+	t.Helper()
+	select {
+	case m := <-s.mails:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+		return smtpMail{}
+	}
+}
+
+// parseTestMail 解析邮件，返回解码后的主题和各部分的正文（按Content-Type）
+func parseTestMail(t *testing.T, data string) (*mail.Message, string, map[string]string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("parse mail: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		// multipart.Reader 会自动解码 quoted-printable
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(part)
+		mediaType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[mediaType] = string(content)
+	}
+	return msg, subject, parts
+}
+
+func newTestEmailNotifier(t *testing.T, port, dataDir string, digestHours string) *EmailNotifier {
+	t.Helper()
+	config := `{"name":"mail","type":"email","host":"127.0.0.1","port":` + port + `,"encryption":"none",` +
+		`"from":"番剧 <bot@example.com>","to":["a@example.com","b@example.com"],"digest_hours":` + digestHours + `}`
+	var notifierConfig NotifierConfig
+	if err := notifierConfig.UnmarshalJSON([]byte(config)); err != nil {
+		t.Fatal(err)
+	}
+	notifierConfig.DataDir = dataDir
+	notifier, err := newEmailNotifier(notifierConfig)
+	if err != nil {
+		t.Fatalf("newEmailNotifier: %v", err)
+	}
+	return notifier.(*EmailNotifier)
+}
+
+func TestEmailSend(t *testing.T) {
+	server := newSMTPStandIn(t)
+	notifier := newTestEmailNotifier(t, server.port(), t.TempDir(), "0")
+
+	event := &Event{Type: EventTaskFailed, Title: "[ANi] 葬送的芙莉蓮 - 12 <b>", FileName: "葬送的芙莉蓮 - 12.mp4", Message: "下载超时", Time: time.Now()}
+	if err := notifier.Send(event); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	m := server.receive(t)
+	if m.from != "bot@example.com" || strings.Join(m.to, ",") != "a@example.com,b@example.com" {
+		t.Fatalf("envelope = %s -> %v", m.from, m.to)
+	}
+
+	msg, subject, parts := parseTestMail(t, m.data)
+	if subject != "❌ 番剧下载失败" {
+		t.Errorf("subject = %q", subject)
+	}
+	if !strings.Contains(msg.Header.Get("From"), "bot@example.com") {
+		t.Errorf("From = %q", msg.Header.Get("From"))
+	}
+	if text := parts["text/plain"]; !strings.Contains(text, "葬送的芙莉蓮 - 12 <b>") || strings.Contains(text, "&lt;") {
+		t.Errorf("text part = %q, want unescaped title", text)
+	}
+	if body := parts["text/html"]; !strings.Contains(body, "&lt;b&gt;") || !strings.Contains(body, "<br>") {
+		t.Errorf("html part = %q, want escaped title and line breaks", body)
+	}
+}
+
+func TestEmailDigestPersisted(t *testing.T) {
+	server := newSMTPStandIn(t)
+	dataDir := t.TempDir()
+	notifier := newTestEmailNotifier(t, server.port(), dataDir, "24")
+
+	now := time.Now()
+	events := []*Event{
+		{Type: EventTaskComplete, Title: "葬送的芙莉莲 - 12", Time: now.Add(-time.Minute)},
+		{Type: EventNewRelease, Title: "间谍过家家 - 25", Time: now.Add(-2 * time.Minute)},
+		{Type: EventNewRelease, Title: "葬送的芙莉莲 - 13", Time: now},
+	}
+	for _, event := range events {
+		if err := notifier.Send(event); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	select {
+	case <-server.mails:
+		t.Fatal("digest event sent immediately")
+	default:
+	}
+
+	// 模拟重启：新创建的渠道恢复保存的事件
+	restarted := newTestEmailNotifier(t, server.port(), dataDir, "24")
+	if len(restarted.pending) != len(events) {
+		t.Fatalf("restored %d events, want %d", len(restarted.pending), len(events))
+	}
+
+	restarted.flushDigest()
+	_, subject, parts := parseTestMail(t, server.receive(t).data)
+	if subject != "📬 番剧更新汇总: 🎬 新番剧 (2)，✅ 下载完成 (1)" {
+		t.Errorf("subject = %q", subject)
+	}
+	text := parts["text/plain"]
+	if strings.Index(text, "间谍过家家") > strings.Index(text, "葬送的芙莉莲 - 13") {
+		t.Errorf("digest not sorted by time: %q", text)
+	}
+
+	if len(restarted.pending) != 0 {
+		t.Errorf("pending = %d after flush, want 0", len(restarted.pending))
+	}
+	if again := newTestEmailNotifier(t, server.port(), dataDir, "24"); len(again.pending) != 0 {
+		t.Errorf("saved pending = %d after flush, want 0", len(again.pending))
+	}
+}
+
+func TestEmailDigestRetainedOnFailure(t *testing.T) {
+	server := newSMTPStandIn(t)
+	port := server.port()
+	server.Close()
+
+	dataDir := t.TempDir()
+	notifier := newTestEmailNotifier(t, port, dataDir, "24")
+
+	now := time.Now()
+	notifier.Send(&Event{Type: EventNewRelease, Title: "过期的事件", Time: now.Add(-emailDigestMaxAge - time.Hour)})
+	for i := 0; i < emailDigestMaxEvents+10; i++ {
+		notifier.Send(&Event{Type: EventNewRelease, Title: "新番剧", Time: now.Add(time.Duration(i) * time.Second)})
+	}
+	if len(notifier.pending) != emailDigestMaxEvents {
+		t.Fatalf("pending = %d, want cap %d", len(notifier.pending), emailDigestMaxEvents)
+	}
+
+	// SMTP服务器不可用，事件保留到下一次，且数量不超过上限
+	notifier.flushDigest()
+	if len(notifier.pending) != emailDigestMaxEvents {
+		t.Fatalf("pending = %d after failed flush, want %d", len(notifier.pending), emailDigestMaxEvents)
+	}
+	if oldest := notifier.pending[0].Time; !oldest.Equal(now.Add(10 * time.Second)) {
+		t.Errorf("oldest retained event at %v, want the earliest events dropped", oldest)
+	}
+
+	if _, err := os.Stat(filepath.Join(dataDir, "email_digest_mail.json")); err != nil {
+		t.Errorf("digest file not saved: %v", err)
+	}
+}
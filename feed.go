@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 订阅源格式
+const (
+	feedFormatRSS2 = "RSS 2.0"
+	feedFormatRSS1 = "RSS 1.0"
+	feedFormatAtom = "Atom"
+)
+
+// Feed 解析后的订阅源，RSS 2.0、RSS 1.0 (RDF) 和 Atom 都转换为同样的结构
+type Feed struct {
+	Format string
+	Title  string
+	Items  []FeedItem
+}
+
+// FeedItem 订阅源中的一个项目
+type FeedItem struct {
+	Title       string
+	Link        string
+	Description string
+	PubDate     string // 原始格式的发布时间，由 parsePublishTime 解析
+	GUID        string // 没有GUID时使用链接或标题
+	Enclosure   Enclosure
+	TorrentLink string // Mikan 的 torrent 元素
+	InfoHash    string // nyaa:infoHash
+	Thumbnail   string // media:thumbnail
+}
+
+// RSS RSS 2.0 结构体定义
+type RSS struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel Channel  `xml:"channel"`
+}
+
+type Channel struct {
+	Title       string `xml:"title"`
+	Description string `xml:"description"`
+	Items       []Item `xml:"item"`
+}
+
+type Item struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	PubDate     string    `xml:"pubDate"`
+	GUID        string    `xml:"guid"`
+	Enclosure   Enclosure `xml:"enclosure"`
+	Torrent     Torrent   `xml:"torrent"`
+	InfoHash    string    `xml:"infoHash"` // nyaa:infoHash
+	Thumbnail   struct {
+		URL string `xml:"url,attr"`
+	} `xml:"thumbnail"` // media:thumbnail
+}
+
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type Torrent struct {
+	XMLName       xml.Name `xml:"torrent"`
+	Xmlns         string   `xml:"xmlns,attr"`
+	Link          string   `xml:"link"`
+	ContentLength string   `xml:"contentLength"`
+	PubDate       string   `xml:"pubDate"`
+}
+
+// RDF RSS 1.0 结构体定义，项目与 channel 平级
+type RDF struct {
+	XMLName xml.Name `xml:"RDF"`
+	Channel struct {
+		Title string `xml:"title"`
+	} `xml:"channel"`
+	Items []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	About       string `xml:"about,attr"` // rdf:about，项目的唯一标识
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Content     string `xml:"encoded"` // content:encoded
+	Date        string `xml:"date"`    // dc:date
+	Enclosure   struct {
+		Resource string `xml:"resource,attr"` // mod_enclosure 的 rdf:resource
+		URL      string `xml:"url,attr"`
+		Length   string `xml:"length,attr"`
+		Type     string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	InfoHash  string `xml:"infoHash"`
+	Thumbnail struct {
+		URL string `xml:"url,attr"`
+	} `xml:"thumbnail"`
+}
+
+// AtomFeed Atom 1.0 结构体定义
+type AtomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Title   string      `xml:"title"`
+	Entries []AtomEntry `xml:"entry"`
+}
+
+type AtomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Links     []AtomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   AtomText   `xml:"summary"`
+	Content   AtomText   `xml:"content"`
+	InfoHash  string     `xml:"infoHash"`
+	Thumbnail struct {
+		URL string `xml:"url,attr"`
+	} `xml:"thumbnail"`
+}
+
+// AtomText Atom 的文本内容，type="xhtml" 时内容是XML子元素而不是转义后的文本
+type AtomText struct {
+	Type     string `xml:"type,attr"`
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// String 文本内容，xhtml 返回子元素的原始XML
+func (at AtomText) String() string {
+	if at.Type == "xhtml" {
+		return strings.TrimSpace(at.InnerXML)
+	}
+	return at.Text
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"` // 为空时等同于 alternate
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// 获取订阅源内容，自动识别 RSS 2.0、RSS 1.0 和 Atom
+func (bm *BangumiMonitor) fetchFeed(ctx context.Context, feedURL string) (*Feed, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	// 设置User-Agent，避免被反爬虫
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("获取RSS失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("RSS请求失败，状态码: %d", resp.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取RSS失败: %v", err)
+	}
+
+	return parseFeed(data)
+}
+
+// parseFeed 按根元素识别订阅源格式并解析
+func parseFeed(data []byte) (*Feed, error) {
+	root, err := feedRootElement(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析RSS失败: %v", err)
+	}
+
+	switch root {
+	case "rss":
+		var rss RSS
+		if err := xml.Unmarshal(data, &rss); err != nil {
+			return nil, fmt.Errorf("解析RSS失败: %v", err)
+		}
+		return rss.feed(), nil
+	case "RDF":
+		var rdf RDF
+		if err := xml.Unmarshal(data, &rdf); err != nil {
+			return nil, fmt.Errorf("解析RSS 1.0失败: %v", err)
+		}
+		return rdf.feed(), nil
+	case "feed":
+		var atom AtomFeed
+		if err := xml.Unmarshal(data, &atom); err != nil {
+			return nil, fmt.Errorf("解析Atom失败: %v", err)
+		}
+		return atom.feed(), nil
+	default:
+		return nil, fmt.Errorf("不支持的订阅源格式，根元素: <%s>", root)
+	}
+}
+
+// feedRootElement 文档根元素的名称（不含命名空间前缀）
+func feedRootElement(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("文档为空")
+		}
+		if err != nil {
+			return "", err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// feed 转换 RSS 2.0
+func (rss *RSS) feed() *Feed {
+	feed := &Feed{Format: feedFormatRSS2, Title: rss.Channel.Title}
+	for _, item := range rss.Channel.Items {
+		feed.Items = append(feed.Items, FeedItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+			PubDate:     item.PubDate,
+			GUID:        item.GUID,
+			Enclosure:   item.Enclosure,
+			TorrentLink: item.Torrent.Link,
+			InfoHash:    item.InfoHash,
+			Thumbnail:   item.Thumbnail.URL,
+		}.normalize())
+	}
+	return feed
+}
+
+// feed 转换 RSS 1.0
+func (rdf *RDF) feed() *Feed {
+	feed := &Feed{Format: feedFormatRSS1, Title: rdf.Channel.Title}
+	for _, item := range rdf.Items {
+		description := item.Description
+		if item.Content != "" {
+			description = item.Content
+		}
+
+		enclosureURL := item.Enclosure.Resource
+		if enclosureURL == "" {
+			enclosureURL = item.Enclosure.URL
+		}
+
+		feed.Items = append(feed.Items, FeedItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: description,
+			PubDate:     item.Date,
+			GUID:        item.About,
+			Enclosure:   Enclosure{URL: enclosureURL, Length: item.Enclosure.Length, Type: item.Enclosure.Type},
+			InfoHash:    item.InfoHash,
+			Thumbnail:   item.Thumbnail.URL,
+		}.normalize())
+	}
+	return feed
+}
+
+// feed 转换 Atom，rel="enclosure" 的链接作为 enclosure，rel="alternate" 的链接作为项目链接
+func (atom *AtomFeed) feed() *Feed {
+	feed := &Feed{Format: feedFormatAtom, Title: atom.Title}
+	for _, entry := range atom.Entries {
+		item := FeedItem{
+			Title:       entry.Title,
+			Description: entry.Content.String(),
+			PubDate:     entry.Published,
+			GUID:        entry.ID,
+			InfoHash:    entry.InfoHash,
+			Thumbnail:   entry.Thumbnail.URL,
+		}
+		if item.Description == "" {
+			item.Description = entry.Summary.String()
+		}
+		if item.PubDate == "" {
+			item.PubDate = entry.Updated
+		}
+
+		for _, link := range entry.Links {
+			switch link.Rel {
+			case "", "alternate":
+				if item.Link == "" {
+					item.Link = link.Href
+				}
+			case "enclosure":
+				if item.Enclosure.URL == "" {
+					item.Enclosure = Enclosure{URL: link.Href, Length: link.Length, Type: link.Type}
+				}
+			}
+		}
+
+		feed.Items = append(feed.Items, item.normalize())
+	}
+	return feed
+}
+
+// normalize 没有GUID时使用链接或标题作为GUID
+// 已有的GUID原样保留，与之前保存的已见记录保持一致
+func (item FeedItem) normalize() FeedItem {
+	item.PubDate = strings.TrimSpace(item.PubDate)
+	for _, fallback := range []string{item.Link, item.Title} {
+		if strings.TrimSpace(item.GUID) != "" {
+			break
+		}
+		item.GUID = strings.TrimSpace(fallback)
+	}
+	return item
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		file   string
+		format string
+		title  string
+		items  []FeedItem
+	}{
+		{
+			file:   "testdata/rss2.xml",
+			format: feedFormatRSS2,
+			title:  "Mikan Project - 葬送的芙莉莲",
+			items: []FeedItem{
+				{
+					Title:       "[LoliHouse] Sousou no Frieren - 12 [WebRip 1080p HEVC-10bit AAC][简繁内封字幕]",
+					Link:        "https://mikanani.me/Home/Episode/0123456789abcdef0123456789abcdef01234567",
+					Description: "[LoliHouse] Sousou no Frieren - 12 [WebRip 1080p HEVC-10bit AAC][简繁内封字幕][619.4 MB]",
+					PubDate:     "Fri, 24 Nov 2023 23:30:00 +0800",
+					GUID:        "[LoliHouse] Sousou no Frieren - 12 [WebRip 1080p HEVC-10bit AAC][简繁内封字幕]",
+					Enclosure: Enclosure{
+						URL:    "https://mikanani.me/Download/20231124/0123456789abcdef0123456789abcdef01234567.torrent",
+						Length: "649487360",
+						Type:   "application/x-bittorrent",
+					},
+					TorrentLink: "https://mikanani.me/Home/Episode/0123456789abcdef0123456789abcdef01234567",
+					Thumbnail:   "https://mikanani.me/images/Bangumi/202310/frieren.jpg",
+				},
+				{
+					Title:       "[ANi] 葬送的芙莉莲 - 13 [1080P][Baha][WEB-DL][AAC AVC][CHT][MP4]",
+					Link:        "https://mikanani.me/Home/Episode/89abcdef0123456789abcdef0123456789abcdef",
+					Description: `<p><img src="https://mikanani.me/images/Bangumi/202310/frieren-13.jpg" /></p><p>magnet:?xt=urn:btih:89abcdef0123456789abcdef0123456789abcdef&amp;dn=Frieren-13</p>`,
+					PubDate:     "Fri, 01 Dec 2023 23:30:00 +0800",
+					GUID:        "[ANi] 葬送的芙莉莲 - 13 [1080P][Baha][WEB-DL][AAC AVC][CHT][MP4]",
+				},
+			},
+		},
+		{
+			file:   "testdata/rss1.xml",
+			format: feedFormatRSS1,
+			title:  "アニメ新着 - 葬送のフリーレン",
+			items: []FeedItem{
+				{
+					Title:       "[Lilith-Raws] Sousou no Frieren - 12 [Baha][WEB-DL][1080p][AVC AAC][CHT][MP4]",
+					Link:        "https://example.jp/anime/release/5012",
+					Description: "葬送のフリーレン 第12話",
+					PubDate:     "2023-11-25T00:05+09:00",
+					GUID:        "https://example.jp/anime/release/5012", // rdf:about
+					// mod_enclosure 的 rdf:resource 作为 enclosure 地址
+					Enclosure: Enclosure{
+						URL:    "https://example.jp/anime/torrent/0123456789abcdef0123456789abcdef01234567.torrent",
+						Length: "649487360",
+						Type:   "application/x-bittorrent",
+					},
+				},
+				{
+					Title:       "[Lilith-Raws] Sousou no Frieren - 13 [Baha][WEB-DL][1080p][AVC AAC][CHT][MP4]",
+					Link:        "https://example.jp/anime/release/5031",
+					Description: `<p>第13話</p><p><a href="magnet:?xt=urn:btih:89abcdef0123456789abcdef0123456789abcdef&dn=Frieren-13">magnet</a></p>`, // content:encoded 优先
+					PubDate:     "2023-12-02T00:05:00+09:00",
+					GUID:        "https://example.jp/anime/release/5031",
+				},
+			},
+		},
+		{
+			file:   "testdata/atom.xml",
+			format: feedFormatAtom,
+			title:  "Anime Releases - Sousou no Frieren",
+			items: []FeedItem{
+				{
+					Title:       "[SubsPlease] Sousou no Frieren - 12 (1080p) [A1B2C3D4].mkv",
+					Link:        "https://example.org/view/1735112", // rel="alternate"
+					Description: "<p>Size: 1.35 GiB</p>",
+					PubDate:     "2023-11-24T15:31:42Z",
+					GUID:        "urn:uuid:6a1d0d8e-8a4f-4f59-9c11-0bd6a1e0f312",
+					// rel="enclosure" 的链接作为 enclosure
+					Enclosure: Enclosure{
+						URL:    "https://example.org/download/1735112.torrent",
+						Length: "1449551462",
+						Type:   "application/x-bittorrent",
+					},
+					InfoHash:  "0123456789abcdef0123456789abcdef01234567",
+					Thumbnail: "https://example.org/posters/frieren.jpg",
+				},
+				{
+					Title:   "[Erai-raws] Sousou no Frieren - 13 [1080p][Multiple Subtitle]",
+					Link:    "https://example.org/view/1738630", // 没有 rel 等同于 alternate
+					PubDate: "2023-12-01T15:30:00+00:00",        // 没有 published 时使用 updated
+					GUID:    "urn:uuid:1f2e3d4c-5b6a-4978-8a9b-0c1d2e3f4a5b",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			feed, err := parseFeed(data)
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+			if feed.Format != tt.format {
+				t.Errorf("Format = %q, want %q", feed.Format, tt.format)
+			}
+			if feed.Title != tt.title {
+				t.Errorf("Title = %q, want %q", feed.Title, tt.title)
+			}
+			if len(feed.Items) != len(tt.items) {
+				t.Fatalf("got %d items, want %d", len(feed.Items), len(tt.items))
+			}
+
+			for i, item := range feed.Items {
+				want := tt.items[i]
+				// xhtml 内容只检查包含磁力链接，不比较缩进
+				if strings.HasPrefix(item.Description, "<div") {
+					if !strings.Contains(item.Description, "magnet:?xt=urn:btih:89abcdef0123456789abcdef0123456789abcdef") {
+						t.Errorf("item %d: xhtml content = %q, want magnet link", i, item.Description)
+					}
+					item.Description = ""
+				}
+				if !reflect.DeepEqual(item, want) {
+					t.Errorf("item %d:\n got  %+v\n want %+v", i, item, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedGUIDFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{
+			name: "rss2",
+			data: `<rss version="2.0"><channel>
+				<item><guid>guid-1</guid><link>https://example.org/1</link><title>One</title></item>
+				<item><link> https://example.org/2 </link><title>Two</title></item>
+				<item><guid>  </guid><title>Three</title></item>
+			</channel></rss>`,
+			want: []string{"guid-1", "https://example.org/2", "Three"},
+		},
+		{
+			name: "rss1",
+			data: `<rdf:RDF xmlns="http://purl.org/rss/1.0/" xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+				<item><link>https://example.org/1</link><title>One</title></item>
+				<item><title>Two</title></item>
+			</rdf:RDF>`,
+			want: []string{"https://example.org/1", "Two"},
+		},
+		{
+			name: "atom",
+			data: `<feed xmlns="http://www.w3.org/2005/Atom">
+				<entry><title>One</title><link rel="enclosure" href="https://example.org/1.torrent"/><link rel="alternate" href="https://example.org/1"/></entry>
+				<entry><title>Two</title><link rel="enclosure" href="https://example.org/2.torrent"/></entry>
+			</feed>`,
+			want: []string{"https://example.org/1", "Two"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+
+			var guids []string
+			for _, item := range feed.Items {
+				guids = append(guids, item.GUID)
+			}
+			if !reflect.DeepEqual(guids, tt.want) {
+				t.Errorf("GUIDs = %q, want %q", guids, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedUnsupported(t *testing.T) {
+	for _, data := range []string{"", "<html><body>Not Found</body></html>", "<rss><channel>"} {
+		if _, err := parseFeed([]byte(data)); err == nil {
+			t.Errorf("parseFeed(%q) succeeded, want error", data)
+		}
+	}
+}
@@ -5,9 +5,9 @@ go 1.24
 require (
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
 	github.com/lyqingye/pikpak-go v0.0.0-20231030033922-981c90c76645
+	golang.org/x/net v0.0.0-20211029224645-99673261e6eb
 )
 
 require (
 	github.com/go-resty/resty/v2 v2.7.0 // indirect
-	golang.org/x/net v0.0.0-20211029224645-99673261e6eb // indirect
 )
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// hexInfoHashRegex 40位十六进制的v1 infohash
+var hexInfoHashRegex = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// normalizeInfoHash 将十六进制或base32形式的infohash统一为小写十六进制
+func normalizeInfoHash(hash string) (string, error) {
+	hash = strings.TrimSpace(hash)
+
+	switch len(hash) {
+	case 40:
+		if !hexInfoHashRegex.MatchString(hash) {
+			return "", fmt.Errorf("无效的十六进制infohash: %s", hash)
+		}
+		return strings.ToLower(hash), nil
+	case 32:
+		raw, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash))
+		if err != nil {
+			return "", fmt.Errorf("无效的base32 infohash: %s", hash)
+		}
+		return hex.EncodeToString(raw), nil
+	}
+
+	return "", fmt.Errorf("infohash长度无效: %s", hash)
+}
+
+// parseMagnetInfoHash 从磁力链接的 xt=urn:btih: 参数中解析infohash
+func parseMagnetInfoHash(magnetLink string) (string, error) {
+	u, err := url.Parse(magnetLink)
+	if err != nil {
+		return "", fmt.Errorf("解析磁力链接失败: %v", err)
+	}
+	if u.Scheme != "magnet" {
+		return "", fmt.Errorf("不是磁力链接: %s", magnetLink)
+	}
+
+	for _, xt := range u.Query()["xt"] {
+		if len(xt) > len("urn:btih:") && strings.EqualFold(xt[:len("urn:btih:")], "urn:btih:") {
+			return normalizeInfoHash(xt[len("urn:btih:"):])
+		}
+	}
+
+	return "", fmt.Errorf("磁力链接中没有btih: %s", magnetLink)
+}
+
+// infoHashFromURL 从种子下载地址中提取infohash
+// Mikan等站点的种子和详情页地址以infohash命名，例如 /Download/20231001/<infohash>.torrent
+func infoHashFromURL(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.Path == "" {
+		return ""
+	}
+
+	name := strings.TrimSuffix(path.Base(u.Path), ".torrent")
+	if !hexInfoHashRegex.MatchString(name) {
+		return ""
+	}
+
+	return strings.ToLower(name)
+}
+
+// resolveInfoHash 解析RSS项目对应的infohash，无法确定时返回空字符串
+func (bm *BangumiMonitor) resolveInfoHash(item FeedItem, link string) string {
+	// Nyaa 在 nyaa:infoHash 元素中直接给出
+	if item.InfoHash != "" {
+		if hash, err := normalizeInfoHash(item.InfoHash); err == nil {
+			return hash
+		}
+	}
+
+	if strings.HasPrefix(link, "magnet:") {
+		hash, err := parseMagnetInfoHash(link)
+		if err != nil {
+			return ""
+		}
+		return hash
+	}
+
+	for _, candidate := range []string{link, item.Enclosure.URL, item.TorrentLink, item.Link} {
+		if hash := infoHashFromURL(candidate); hash != "" {
+			return hash
+		}
+	}
+
+	return ""
+}
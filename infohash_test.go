@@ -0,0 +1,115 @@
+package main
+
+import "testing"
+
+const testInfoHash = "0123456789abcdef0123456789abcdef01234567"
+
+func TestNormalizeInfoHash(t *testing.T) {
+	tests := []struct {
+		hash string
+		want string // 为空时应返回错误
+	}{
+		{testInfoHash, testInfoHash},
+		{"0123456789ABCDEF0123456789ABCDEF01234567", testInfoHash},
+		{" 0123456789AbCdEf0123456789aBcDeF01234567\n", testInfoHash},
+		{"AERUKZ4JVPG66AJDIVTYTK6N54ASGRLH", testInfoHash},
+		{"aeruKZ4JVPG66AJDIVTYTK6N54ASGRLH", testInfoHash},
+		{"", ""},
+		{"0123456789abcdef0123456789abcdef0123456", ""},          // 39位
+		{"0123456789abcdef0123456789abcdef012345678", ""},        // 41位
+		{"0123456789abcdef0123456789abcdef0123456g", ""},         // 非十六进制
+		{"AERUKZ4JVPG66AJDIVTYTK6N54ASGRL1", ""},                 // 非base32
+		{"AERUKZ4JVPG66AJDIVTYTK6N54ASGRL", ""},                  // 31位
+		{"1220" + testInfoHash + "abcdef0123456789abcdef01", ""}, // v2 multihash
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeInfoHash(tt.hash)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("normalizeInfoHash(%q) = %q, want error", tt.hash, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeInfoHash(%q) = %q, %v, want %q", tt.hash, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseMagnetInfoHash(t *testing.T) {
+	tests := []struct {
+		link string
+		want string // 为空时应返回错误
+	}{
+		{"magnet:?xt=urn:btih:" + testInfoHash, testInfoHash},
+		{"magnet:?xt=urn:btih:AERUKZ4JVPG66AJDIVTYTK6N54ASGRLH&dn=Frieren", testInfoHash},
+		{"magnet:?dn=Frieren&XT=urn:btih:0123456789ABCDEF0123456789ABCDEF01234567", ""}, // 参数名区分大小写
+		{"magnet:?xt=URN:BTIH:0123456789ABCDEF0123456789ABCDEF01234567", testInfoHash},
+		// 混合种子同时有v2的btmh和v1的btih
+		{"magnet:?xt=urn:btmh:1220" + testInfoHash + "abcdef0123456789abcdef01&xt=urn:btih:" + testInfoHash, testInfoHash},
+		{"magnet:?xt=urn:ed2k:31D6CFE0D16AE931B73C59D7E0C089C0&xt=urn:btih:AERUKZ4JVPG66AJDIVTYTK6N54ASGRLH", testInfoHash},
+		{"magnet:?xt=urn:btmh:1220" + testInfoHash + "abcdef0123456789abcdef01", ""},
+		{"magnet:?xt=urn:btih:", ""},
+		{"magnet:?xt=urn:btih:0123456789abcdef", ""},
+		{"magnet:?dn=Frieren", ""},
+		{"https://example.org/?xt=urn:btih:" + testInfoHash, ""},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMagnetInfoHash(tt.link)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parseMagnetInfoHash(%q) = %q, want error", tt.link, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseMagnetInfoHash(%q) = %q, %v, want %q", tt.link, got, err, tt.want)
+		}
+	}
+}
+
+func TestInfoHashFromURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{"https://mikanani.me/Download/20231124/" + testInfoHash + ".torrent", testInfoHash},
+		{"https://mikanani.me/Download/20231124/0123456789ABCDEF0123456789ABCDEF01234567.torrent?token=x", testInfoHash},
+		{"https://mikanani.me/Home/Episode/" + testInfoHash, testInfoHash},
+		{"https://nyaa.si/download/1735112.torrent", ""},
+		{"https://example.org/" + testInfoHash + "-frieren.torrent", ""},
+		{"https://example.org/download?hash=" + testInfoHash, ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := infoHashFromURL(tt.link); got != tt.want {
+			t.Errorf("infoHashFromURL(%q) = %q, want %q", tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestResolveInfoHash(t *testing.T) {
+	bm := &BangumiMonitor{}
+	tests := []struct {
+		name string
+		item FeedItem
+		link string
+		want string
+	}{
+		{"nyaa infoHash", FeedItem{InfoHash: "0123456789ABCDEF0123456789ABCDEF01234567"}, "https://nyaa.si/download/1.torrent", testInfoHash},
+		{"magnet", FeedItem{}, "magnet:?xt=urn:btih:AERUKZ4JVPG66AJDIVTYTK6N54ASGRLH", testInfoHash},
+		{"invalid magnet", FeedItem{Link: "https://mikanani.me/Home/Episode/" + testInfoHash}, "magnet:?xt=urn:btih:bad", ""},
+		{"enclosure", FeedItem{Enclosure: Enclosure{URL: "https://mikanani.me/Download/20231124/" + testInfoHash + ".torrent"}}, "https://example.org/1.torrent", testInfoHash},
+		{"episode link", FeedItem{Link: "https://mikanani.me/Home/Episode/" + testInfoHash}, "", testInfoHash},
+		{"unknown", FeedItem{Link: "https://nyaa.si/view/1"}, "https://nyaa.si/download/1.torrent", ""},
+	}
+
+	for _, tt := range tests {
+		if got := bm.resolveInfoHash(tt.item, tt.link); got != tt.want {
+			t.Errorf("%s: resolveInfoHash = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,111 +1,53 @@
 package main
 
 import (
-	"encoding/xml"
+	"context"
 	"fmt"
+	"html"
 	"log"
-	"net/http"
+	"os/signal"
 	"regexp"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
-// RSS RSS结构体定义
-type RSS struct {
-	XMLName xml.Name `xml:"rss"`
-	Channel Channel  `xml:"channel"`
-}
-
-type Channel struct {
-	Title       string `xml:"title"`
-	Description string `xml:"description"`
-	Items       []Item `xml:"item"`
-}
-
-type Item struct {
-	Title       string    `xml:"title"`
-	Link        string    `xml:"link"`
-	Description string    `xml:"description"`
-	PubDate     string    `xml:"pubDate"`
-	GUID        string    `xml:"guid"`
-	Enclosure   Enclosure `xml:"enclosure"`
-	Torrent     Torrent   `xml:"torrent"`
-}
-
-type Enclosure struct {
-	URL    string `xml:"url,attr"`
-	Length string `xml:"length,attr"`
-	Type   string `xml:"type,attr"`
-}
-
-type Torrent struct {
-	XMLName       xml.Name `xml:"torrent"`
-	Xmlns         string   `xml:"xmlns,attr"`
-	Link          string   `xml:"link"`
-	ContentLength string   `xml:"contentLength"`
-	PubDate       string   `xml:"pubDate"`
-}
-
 // 番剧监听器
 type BangumiMonitor struct {
-	config           *Config
-	downloader       *OfflineDownloader
-	seenItems        map[string]bool
-	mutex            sync.RWMutex
-	lastChecked      time.Time
-	telegramNotifier *TelegramNotifier
-}
-
-// 获取RSS内容
-func (bm *BangumiMonitor) fetchRSS(rssURL string) (*RSS, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	req, err := http.NewRequest("GET", rssURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
-	}
-
-	// 设置User-Agent，避免被反爬虫
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("获取RSS失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("RSS请求失败，状态码: %d", resp.StatusCode)
-	}
-
-	var rss RSS
-	decoder := xml.NewDecoder(resp.Body)
-	err = decoder.Decode(&rss)
-	if err != nil {
-		return nil, fmt.Errorf("解析RSS失败: %v", err)
-	}
-
-	return &rss, nil
+	config        *Config
+	downloader    *OfflineDownloader // PikPak，未配置时为nil
+	downloaders   map[string]Downloader
+	store         *SeenStore
+	subscriptions []*Subscription
+	overrides     *SubscriptionOverrides
+	subsMutex     sync.RWMutex
+	checkMutex    sync.Mutex // 定期检查、/check 和 /sub 命令不同时进行
+	tracker       *TaskTracker
+	lastChecked   time.Time
+	notifier      *NotificationDispatcher
+	posters       *BangumiPosters // 没有渠道需要图片时为nil
+	feedErrors    map[string]bool // 上次检查失败的订阅，只在首次失败时通知
+	quotaWarned   bool
 }
 
 // 从描述或链接中提取磁力链接
-func (bm *BangumiMonitor) extractMagnetLink(item Item) string {
-	// 优先从torrent元素中提取
-	if item.Torrent.Link != "" {
-		log.Printf("🔗 从torrent元素获取链接: %s", item.Torrent.Link)
-		return item.Torrent.Link
-	}
-
+// 找不到磁力链接时返回种子文件地址，由 resolveDownloadLink 在提交前转换为磁力链接
+func (bm *BangumiMonitor) extractMagnetLink(item FeedItem) string {
 	// 磁力链接正则表达式
 	magnetRegex := regexp.MustCompile(`magnet:\?[^"'\s<>]+`)
 
-	// 检查描述中的磁力链接
+	// 检查enclosure
+	if item.Enclosure.URL != "" && strings.HasPrefix(item.Enclosure.URL, "magnet:") {
+		log.Printf("🔗 从enclosure获取磁力链接: %s", item.Enclosure.URL)
+		return item.Enclosure.URL
+	}
+
+	// 检查描述中的磁力链接，描述是HTML时链接中的 & 会被转义为 &amp;
 	if matches := magnetRegex.FindStringSubmatch(item.Description); len(matches) > 0 {
-		log.Printf("🔗 从描述中提取磁力链接: %s", matches[0])
-		return matches[0]
+		magnetLink := html.UnescapeString(matches[0])
+		log.Printf("🔗 从描述中提取磁力链接: %s", magnetLink)
+		return magnetLink
 	}
 
 	// 检查链接中的磁力链接
@@ -114,23 +56,43 @@ func (bm *BangumiMonitor) extractMagnetLink(item Item) string {
 		return matches[0]
 	}
 
-	// 检查enclosure
-	if item.Enclosure.URL != "" && strings.HasPrefix(item.Enclosure.URL, "magnet:") {
-		log.Printf("🔗 从enclosure获取磁力链接: %s", item.Enclosure.URL)
+	// 种子文件链接，提交前会下载并转换为磁力链接
+	for _, link := range []string{item.Enclosure.URL, item.TorrentLink, item.Link} {
+		if isTorrentURL(link) {
+			log.Printf("🔗 发现种子文件链接: %s", link)
+			return link
+		}
+	}
+	if item.Enclosure.URL != "" && item.Enclosure.Type == "application/x-bittorrent" {
+		log.Printf("🔗 发现种子文件链接: %s", item.Enclosure.URL)
 		return item.Enclosure.URL
 	}
 
-	// 如果torrent.link不是磁力链接，可能是种子文件链接，需要转换
-	if item.Torrent.Link != "" && strings.HasSuffix(item.Torrent.Link, ".torrent") {
-		log.Printf("🔗 发现种子文件链接: %s", item.Torrent.Link)
-		// 这里可以选择下载种子文件并转换为磁力链接，或者直接使用种子文件链接
-		return item.Torrent.Link
+	// 从torrent元素中提取
+	if item.TorrentLink != "" {
+		log.Printf("🔗 从torrent元素获取链接: %s", item.TorrentLink)
+		return item.TorrentLink
 	}
 
 	log.Printf("⚠️  未找到磁力链接或种子文件: %s", item.Title)
 	return ""
 }
 
+// 将种子文件链接转换为磁力链接，转换失败时仍使用原链接交给PikPak处理
+func (bm *BangumiMonitor) resolveDownloadLink(ctx context.Context, link string) (string, *TorrentMeta) {
+	if strings.HasPrefix(link, "magnet:") || !strings.HasPrefix(link, "http") {
+		return link, nil
+	}
+
+	magnetLink, meta, err := bm.convertTorrentToMagnet(ctx, link)
+	if err != nil {
+		log.Printf("⚠️  种子文件转换失败，直接提交原链接: %v", err)
+		return link, nil
+	}
+
+	return magnetLink, meta
+}
+
 // 清理文件名
 func (bm *BangumiMonitor) cleanFileName(title string) string {
 	// 移除HTML标签
@@ -170,39 +132,28 @@ func (bm *BangumiMonitor) cleanFileName(title string) string {
 }
 
 // 检查是否应该下载该项目
-func (bm *BangumiMonitor) shouldDownload(item Item) bool {
+func (bm *BangumiMonitor) shouldDownload(item FeedItem, sub *Subscription, release *Release) bool {
 	title := strings.ToLower(item.Title)
 
-	// 检查关键词过滤
-	if len(bm.config.RSS.Keywords) > 0 {
-		hasKeyword := false
-		for _, keyword := range bm.config.RSS.Keywords {
-			if strings.Contains(title, strings.ToLower(keyword)) {
-				hasKeyword = true
-				break
-			}
-		}
-		if !hasKeyword {
+	// 检查关键词过滤，任意一条规则匹配即可
+	if len(sub.includeRules) > 0 {
+		if _, ok := matchAnyRule(sub.includeRules, item.Title); !ok {
 			log.Printf("🔍 跳过（无匹配关键词）: %s", item.Title)
 			return false
 		}
 	}
 
 	// 检查排除关键词
-	if len(bm.config.RSS.ExcludeKeywords) > 0 {
-		for _, keyword := range bm.config.RSS.ExcludeKeywords {
-			if strings.Contains(title, strings.ToLower(keyword)) {
-				log.Printf("🚫 跳过（匹配排除关键词 '%s'）: %s", keyword, item.Title)
-				return false
-			}
-		}
+	if rule, ok := matchAnyRule(sub.excludeRules, item.Title); ok {
+		log.Printf("🚫 跳过（匹配排除关键词 '%s'）: %s", rule, item.Title)
+		return false
 	}
 
 	// 检查分辨率过滤
-	if len(bm.config.RSS.Resolutions) > 0 {
+	if len(sub.Resolutions) > 0 {
 		hasResolution := false
-		for _, resolution := range bm.config.RSS.Resolutions {
-			if strings.Contains(title, strings.ToLower(resolution)) {
+		for _, resolution := range sub.Resolutions {
+			if strings.Contains(title, strings.ToLower(resolution)) || strings.EqualFold(release.Resolution, resolution) {
 				hasResolution = true
 				break
 			}
@@ -213,32 +164,103 @@ func (bm *BangumiMonitor) shouldDownload(item Item) bool {
 		}
 	}
 
+	// 检查字幕组
+	if !sub.matchGroup(release.Group) {
+		log.Printf("👥 跳过（非首选字幕组 '%s'）: %s", release.Group, item.Title)
+		return false
+	}
+
+	// 检查是否通过通知按钮屏蔽了该番剧
+	if bm.isMuted(sub.Name, release.Series) {
+		log.Printf("🔇 跳过（已屏蔽番剧 '%s'）: %s", release.Series, item.Title)
+		return false
+	}
+
 	return true
 }
 
+// submitMaxAttempts 提交下载任务最多尝试的次数，之后放弃该项目
+const submitMaxAttempts = 5
+
+// submitFailed 记录一次提交失败，返回true表示下次检查时重试
+// 达到 submitMaxAttempts 次后把项目标记为失败并发送通知，不再重试
+func (bm *BangumiMonitor) submitFailed(record *SeenRecord, sub *Subscription, fileName string, err error) bool {
+	record.Attempts = bm.store.Attempts(record.GUID) + 1
+	record.Error = err.Error()
+
+	if record.Attempts < submitMaxAttempts {
+		log.Printf("❌ 添加下载任务失败 (%d/%d)，下次检查时重试: %v", record.Attempts, submitMaxAttempts, err)
+		if err := bm.store.Add(*record); err != nil {
+			log.Printf("❌ 保存已见项目失败: %v", err)
+		}
+		return true
+	}
+
+	log.Printf("❌ 添加下载任务失败 %d 次，放弃: %s", record.Attempts, record.Title)
+	record.Failed = true
+
+	downloader := sub.Downloader
+	if downloader == "" {
+		downloader = pikpakDownloaderName
+	}
+	bm.sendNotification(&Event{
+		Type:         EventTaskFailed,
+		Title:        record.Title,
+		FileName:     fileName,
+		Subscription: sub.Name,
+		Downloader:   downloader,
+		Retries:      record.Attempts - 1,
+		Message:      fmt.Sprintf("添加下载任务失败: %v", err),
+		Release:      ParseRelease(record.Title),
+	})
+	return false
+}
+
 // 检查单个RSS源的新项目
-func (bm *BangumiMonitor) checkRSSSource(rssURL string) error {
-	log.Printf("🔍 检查RSS源: %s", rssURL)
+func (bm *BangumiMonitor) checkRSSSource(ctx context.Context, sub *Subscription) error {
+	rssURL := sub.URL
+	log.Printf("🔍 检查订阅 [%s]: %s", sub.Name, rssURL)
 
-	rss, err := bm.fetchRSS(rssURL)
+	feed, err := bm.fetchFeed(ctx, rssURL)
 	if err != nil {
 		return fmt.Errorf("获取RSS失败: %v", err)
 	}
 
-	log.Printf("📡 获取到 %d 个RSS项目 (频道: %s)", len(rss.Channel.Items), rss.Channel.Title)
+	log.Printf("📡 获取到 %d 个%s项目 (频道: %s)", len(feed.Items), feed.Format, feed.Title)
+
+	feedKnown := bm.store.HasFeed(rssURL)
 
 	newItemsCount := 0
-	for i, item := range rss.Channel.Items {
-		log.Printf("📄 处理项目 %d/%d: %s", i+1, len(rss.Channel.Items), item.Title)
+	for i, item := range feed.Items {
+		// 收到退出信号时不再处理后续项目，已提交的任务会正常完成
+		if ctx.Err() != nil {
+			log.Printf("🛑 停止处理RSS源: %s", rssURL)
+			return ctx.Err()
+		}
+
+		log.Printf("📄 处理项目 %d/%d: %s", i+1, len(feed.Items), item.Title)
 
-		bm.mutex.Lock()
-		alreadySeen := bm.seenItems[item.GUID]
-		bm.mutex.Unlock()
+		if !bm.store.Seen(item.GUID) {
+			magnetLink := bm.extractMagnetLink(item)
 
-		if !alreadySeen {
-			bm.mutex.Lock()
-			bm.seenItems[item.GUID] = true
-			bm.mutex.Unlock()
+			record := SeenRecord{
+				GUID:         item.GUID,
+				InfoHash:     bm.resolveInfoHash(item, magnetLink),
+				Title:        item.Title,
+				Feed:         rssURL,
+				Subscription: sub.Name,
+			}
+
+			// 不同RSS源的同一个种子GUID不同，按infohash去重
+			if record.InfoHash != "" {
+				if existing, ok := bm.store.SeenInfoHash(record.InfoHash); ok {
+					log.Printf("🔁 跳过重复种子: %s (infohash: %s, 首次出现于: %s)", item.Title, record.InfoHash, existing.Feed)
+					if err := bm.store.Add(record); err != nil {
+						log.Printf("❌ 保存已见项目失败: %v", err)
+					}
+					continue
+				}
+			}
 
 			// 解析发布时间
 			pubTime, err := bm.parsePublishTime(item.PubDate)
@@ -248,36 +270,88 @@ func (bm *BangumiMonitor) checkRSSSource(rssURL string) error {
 			}
 
 			// 只处理最近的项目（避免首次运行下载所有历史内容）
-			if pubTime.After(bm.lastChecked) {
+			// 已有记录的RSS源不受时间限制，停机期间发布的项目也会被补上
+			if feedKnown || pubTime.After(bm.lastChecked) {
 				log.Printf("🆕 发现新项目: %s", item.Title)
 				log.Printf("   📅 发布时间: %s", pubTime.Format("2006-01-02 15:04:05"))
+				release := ParseRelease(item.Title)
+				log.Printf("   🧩 解析结果: %s", release.Summary())
+
+				if bm.shouldDownload(item, sub, release) {
+					downloadLink, meta := bm.resolveDownloadLink(ctx, magnetLink)
+					if meta != nil && record.InfoHash == "" {
+						record.InfoHash = meta.InfoHash
+						if existing, ok := bm.store.SeenInfoHash(record.InfoHash); ok {
+							log.Printf("🔁 跳过重复种子: %s (infohash: %s, 首次出现于: %s)", item.Title, record.InfoHash, existing.Feed)
+							downloadLink = ""
+						}
+					}
 
-				if bm.shouldDownload(item) {
-					magnetLink := bm.extractMagnetLink(item)
-					if magnetLink != "" {
+					if downloadLink != "" {
 						log.Printf("🎬 准备下载: %s", item.Title)
 
 						fileName := bm.cleanFileName(item.Title)
 						log.Printf("📁 清理后文件名: %s", fileName)
 
-						// 添加到PikPak下载
-						err := bm.downloader.AddMagnetTask(fileName, magnetLink)
+						// 添加到订阅选择的下载器
+						taskID := ""
+						folderID := ""
+						downloader, err := bm.downloaderFor(sub)
+						if err == nil {
+							folderID, err = subscriptionFolder(sub, downloader)
+						}
+						if err == nil {
+							taskID, err = downloader.AddTask(fileName, downloadLink, folderID)
+						}
 						if err != nil {
-							log.Printf("❌ 添加下载任务失败: %v", err)
+							// 未达到次数上限时只记录失败次数，下次检查时重试
+							if bm.submitFailed(&record, sub, fileName, err) {
+								continue
+							}
 						} else {
 							log.Printf("✅ 成功添加下载任务: %s", fileName)
 							newItemsCount++
 
+							record.TaskID = taskID
+							record.SubmittedAt = time.Now()
+
+							// 跟踪任务直到完成
+							bm.tracker.Track(&TrackedTask{
+								ID:           taskID,
+								FileName:     fileName,
+								Title:        item.Title,
+								Subscription: sub.Name,
+								Downloader:   downloader.Name(),
+								InfoHash:     record.InfoHash,
+								FolderID:     folderID,
+								SubmittedAt:  record.SubmittedAt,
+							})
+
 							// 发送通知
-							bm.sendNotification(fileName, item.Title)
+							bm.sendNotification(&Event{
+								Type:         EventNewRelease,
+								Title:        item.Title,
+								FileName:     fileName,
+								Subscription: sub.Name,
+								Release:      release,
+								Downloader:   downloader.Name(),
+								TaskID:       taskID,
+								FolderID:     folderID,
+								ImageURL:     bm.releaseImage(ctx, &item, release),
+								SubmittedAt:  record.SubmittedAt,
+							})
 						}
-					} else {
+					} else if magnetLink == "" {
 						log.Printf("⚠️  未找到磁力链接或种子文件: %s", item.Title)
 					}
 				}
 			} else {
 				log.Printf("⏰ 跳过旧项目: %s (发布时间: %s)", item.Title, pubTime.Format("2006-01-02 15:04:05"))
 			}
+
+			if err := bm.store.Add(record); err != nil {
+				log.Printf("❌ 保存已见项目失败: %v", err)
+			}
 		} else {
 			log.Printf("👁️  跳过已见项目: %s", item.Title)
 		}
@@ -300,6 +374,7 @@ func (bm *BangumiMonitor) parsePublishTime(pubDate string) (time.Time, error) {
 		"Mon, 02 Jan 2006 15:04:05 -0700",
 		"Mon, 02 Jan 2006 15:04:05 MST",
 		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04Z07:00", // RSS 1.0 的 dc:date 可以省略秒
 		"2006-01-02 15:04:05",
 	}
 
@@ -313,75 +388,130 @@ func (bm *BangumiMonitor) parsePublishTime(pubDate string) (time.Time, error) {
 }
 
 // 发送通知
-func (bm *BangumiMonitor) sendNotification(fileName, originalTitle string) {
-	// 如果启用了QQ通知
-	if bm.config.QQ.Enabled && bm.config.QQ.BotURL != "" {
-		message := fmt.Sprintf("🎬 新番剧下载通知\n\n📺 标题: %s\n📁 文件名: %s\n⏰ 时间: %s",
-			originalTitle, fileName, time.Now().Format("2006-01-02 15:04:05"))
-
-		// 创建QQ机器人客户端并发送消息
-		bot := NewQQBot(bm.config.QQ.BotURL, bm.config.QQ.Token)
-
-		// 向所有配置的用户发送通知
-		for _, userID := range bm.config.QQ.NotifyUsers {
-			response, err := bot.SendPrivateMessage(userID, message)
-			if err != nil {
-				log.Printf("❌ 发送QQ通知失败 (用户: %s): %v", userID, err)
-			} else {
-				log.Printf("✅ QQ通知发送成功 (用户: %s): %s", userID, fileName)
-				log.Printf("📱 响应: %s", response)
-			}
-		}
-	}
-
-	// 如果启用了Telegram通知
-	if bm.config.Telegram.Enabled && bm.telegramNotifier != nil {
-		message := fmt.Sprintf("🎬 *新番剧下载通知*\n\n📺 *标题:* %s\n📁 *文件名:* %s\n⏰ *时间:* %s",
-			originalTitle, fileName, time.Now().Format("2006-01-02 15:04:05"))
+func (bm *BangumiMonitor) sendNotification(event *Event) {
+	bm.notifier.Dispatch(event)
+}
 
-		err := bm.telegramNotifier.SendMessage(message)
-		if err != nil {
-			log.Printf("❌ 发送Telegram通知失败: %v", err)
-		} else {
-			log.Printf("✅ Telegram通知发送成功: %s", fileName)
-		}
+// 任务结束：完成的任务先整理到媒体库目录，再发送通知
+func (bm *BangumiMonitor) onTaskFinished(result *TaskResult) {
+	if result.Success {
+		bm.organizeTask(result)
 	}
+	bm.sendTaskNotification(result)
+}
+
+// 发送任务完成或失败通知
+func (bm *BangumiMonitor) sendTaskNotification(result *TaskResult) {
+	task := result.Task
+
+	event := &Event{
+		Type:         EventTaskComplete,
+		Title:        task.Title,
+		FileName:     task.FileName,
+		Subscription: task.Subscription,
+		Downloader:   task.downloaderName(),
+		TaskID:       task.ID,
+		FolderID:     task.FolderID,
+		FileSize:     result.FileSize,
+		Duration:     result.Duration,
+		Retries:      task.Retries,
+		Message:      result.Message,
+		Release:      ParseRelease(task.Title),
+		SubmittedAt:  task.SubmittedAt,
+	}
+	event.ImageURL = bm.releaseImage(context.Background(), nil, event.Release)
+	if !result.Success {
+		event.Type = EventTaskFailed
+	}
+
+	bm.sendNotification(event)
 }
 
 // 初始化已见项目（避免首次运行下载所有历史内容）
-func (bm *BangumiMonitor) initializeSeenItems() {
+// 已在存储中有记录的RSS源会跳过，只有新加入的RSS源才会把现有项目全部标记为已见
+func (bm *BangumiMonitor) initializeSeenItems(ctx context.Context) {
 	log.Println("🔄 初始化已见项目...")
 
 	totalItems := 0
-	for i, rssURL := range bm.config.RSS.URLs {
-		log.Printf("📡 初始化RSS源 %d/%d: %s", i+1, len(bm.config.RSS.URLs), rssURL)
+	subscriptions := bm.subscriptionList()
+	for i, sub := range subscriptions {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if bm.store.HasFeed(sub.URL) {
+			log.Printf("💾 订阅 %d/%d 已有记录，跳过初始化: %s", i+1, len(subscriptions), sub.Name)
+			continue
+		}
+
+		log.Printf("📡 初始化订阅 %d/%d: %s", i+1, len(subscriptions), sub.Name)
 
-		rss, err := bm.fetchRSS(rssURL)
+		count, err := bm.initializeFeed(ctx, sub)
 		if err != nil {
 			log.Printf("❌ 初始化RSS源失败: %v", err)
 			continue
 		}
+		totalItems += count
+	}
+
+	log.Printf("🎯 初始化完成，共标记 %d 个现有项目", totalItems)
+}
+
+// 将订阅的RSS源中现有的项目全部标记为已见，返回标记的数量
+func (bm *BangumiMonitor) initializeFeed(ctx context.Context, sub *Subscription) (int, error) {
+	feed, err := bm.fetchFeed(ctx, sub.URL)
+	if err != nil {
+		return 0, err
+	}
 
-		bm.mutex.Lock()
-		for _, item := range rss.Channel.Items {
-			bm.seenItems[item.GUID] = true
+	for _, item := range feed.Items {
+		if bm.store.Seen(item.GUID) {
+			continue
 		}
-		bm.mutex.Unlock()
 
-		log.Printf("✅ 已标记 %d 个现有项目", len(rss.Channel.Items))
-		totalItems += len(rss.Channel.Items)
+		err := bm.store.Add(SeenRecord{
+			GUID:         item.GUID,
+			InfoHash:     bm.resolveInfoHash(item, bm.extractMagnetLink(item)),
+			Title:        item.Title,
+			Feed:         sub.URL,
+			Subscription: sub.Name,
+		})
+		if err != nil {
+			log.Printf("❌ 保存已见项目失败: %v", err)
+		}
 	}
 
-	log.Printf("🎯 初始化完成，共标记 %d 个现有项目", totalItems)
+	log.Printf("✅ 已标记 %d 个现有项目", len(feed.Items))
+	return len(feed.Items), nil
 }
 
 // 显示配置信息
 func (bm *BangumiMonitor) showConfig() {
 	log.Printf("⚙️  配置信息:")
-	log.Printf("   📡 RSS源数量: %d", len(bm.config.RSS.URLs))
+	subscriptions := bm.subscriptionList()
+	log.Printf("   📡 订阅数量: %d", len(subscriptions))
+
+	for i, sub := range subscriptions {
+		log.Printf("      %d. [%s] %s", i+1, sub.Name, sub.URL)
 
-	for i, url := range bm.config.RSS.URLs {
-		log.Printf("      %d. %s", i+1, url)
+		if len(sub.Keywords) > 0 {
+			log.Printf("         🔍 关键词过滤: %v", sub.Keywords)
+		}
+		if len(sub.ExcludeKeywords) > 0 {
+			log.Printf("         🚫 排除关键词: %v", sub.ExcludeKeywords)
+		}
+		if len(sub.Resolutions) > 0 {
+			log.Printf("         📺 分辨率过滤: %v", sub.Resolutions)
+		}
+		if len(sub.Groups) > 0 {
+			log.Printf("         👥 首选字幕组: %v", sub.Groups)
+		}
+		if sub.Downloader != "" {
+			log.Printf("         ⬇️  下载器: %s", sub.Downloader)
+		}
+		if sub.FolderPath != "" {
+			log.Printf("         📁 下载目录: %s", sub.FolderPath)
+		}
 	}
 
 	checkInterval := time.Duration(bm.config.RSS.CheckIntervalMinutes) * time.Minute
@@ -390,31 +520,97 @@ func (bm *BangumiMonitor) showConfig() {
 	}
 	log.Printf("   ⏱️  检查间隔: %v", checkInterval)
 
-	if len(bm.config.RSS.Keywords) > 0 {
-		log.Printf("   🔍 关键词过滤: %v", bm.config.RSS.Keywords)
+	log.Printf("   💾 数据目录: %s", bm.store.path)
+	notifiers := bm.notifier.Notifiers()
+	names := make([]string, 0, len(notifiers))
+	for _, n := range notifiers {
+		names = append(names, n.Name())
+	}
+	log.Printf("   📱 通知渠道: %v", names)
+}
+
+// 检查所有RSS源
+func (bm *BangumiMonitor) checkAllSources(ctx context.Context) {
+	bm.checkMutex.Lock()
+	defer bm.checkMutex.Unlock()
+
+	for _, sub := range bm.subscriptionList() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := bm.checkRSSSource(ctx, sub)
+		if ctx.Err() != nil {
+			return
+		}
+		bm.reportFeedStatus(sub, err)
+	}
+
+	bm.checkQuota()
+}
+
+// 记录订阅的检查结果，连续失败时只通知一次，恢复后重新计算
+func (bm *BangumiMonitor) reportFeedStatus(sub *Subscription, err error) {
+	if err == nil {
+		if bm.feedErrors[sub.Name] {
+			log.Printf("✅ 订阅 [%s] 已恢复", sub.Name)
+			delete(bm.feedErrors, sub.Name)
+		}
+		return
+	}
+
+	log.Printf("❌ 检查订阅 [%s] 失败: %v", sub.Name, err)
+	if bm.feedErrors[sub.Name] {
+		return
 	}
+	bm.feedErrors[sub.Name] = true
+
+	bm.sendNotification(&Event{
+		Type:         EventFeedError,
+		Subscription: sub.Name,
+		Feed:         sub.URL,
+		Message:      err.Error(),
+	})
+}
 
-	if len(bm.config.RSS.ExcludeKeywords) > 0 {
-		log.Printf("   🚫 排除关键词: %v", bm.config.RSS.ExcludeKeywords)
+// 检查PikPak存储空间，使用率超过阈值时提醒一次，降到阈值以下后重新计算
+func (bm *BangumiMonitor) checkQuota() {
+	threshold := bm.config.Pikpak.QuotaWarningPercent
+	if threshold <= 0 || bm.downloader == nil {
+		return
 	}
 
-	if len(bm.config.RSS.Resolutions) > 0 {
-		log.Printf("   📺 分辨率过滤: %v", bm.config.RSS.Resolutions)
+	usage, limit, err := bm.downloader.Quota()
+	if err != nil {
+		log.Printf("⚠️  %v", err)
+		return
+	}
+	if quotaPercent(usage, limit) < threshold {
+		bm.quotaWarned = false
+		return
 	}
+	if bm.quotaWarned {
+		return
+	}
+	bm.quotaWarned = true
 
-	log.Printf("   📱 QQ通知: %v", bm.config.QQ.Enabled)
-	log.Printf("   📱 Telegram通知: %v", bm.config.Telegram.Enabled)
+	log.Printf("💾 PikPak存储空间不足: %s / %s", formatSize(usage), formatSize(limit))
+	bm.sendNotification(&Event{
+		Type:       EventQuotaWarning,
+		QuotaUsage: usage,
+		QuotaLimit: limit,
+	})
 }
 
-// 开始监听所有RSS源
-func (bm *BangumiMonitor) StartMonitoring() {
+// 开始监听所有RSS源，直到ctx被取消
+func (bm *BangumiMonitor) StartMonitoring(ctx context.Context) {
 	log.Println("🚀 启动番剧监听器...")
 
 	// 显示配置信息
 	bm.showConfig()
 
 	// 初始化已见项目
-	bm.initializeSeenItems()
+	bm.initializeSeenItems(ctx)
 
 	checkInterval := time.Duration(bm.config.RSS.CheckIntervalMinutes) * time.Minute
 	if checkInterval == 0 {
@@ -426,46 +622,160 @@ func (bm *BangumiMonitor) StartMonitoring() {
 	defer ticker.Stop()
 
 	log.Println("🎬 开始监听番剧更新...")
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("👋 番剧监听器已停止")
+			return
+		case <-ticker.C:
+			log.Printf("⏰ 开始定期检查: %s", time.Now().Format("2006-01-02 15:04:05"))
+			bm.checkAllSources(ctx)
+		}
+	}
 }
 
 func main() {
 	log.Printf("🚀 启动番剧监听器...")
 
-	// 创建离线下载器
-	downloader, err := NewOfflineDownloader("config.json")
+	// 加载配置
+	config, err := parseJSONFile("config.json")
+	if err != nil {
+		log.Fatalf("❌ 加载配置失败: %v", err)
+	}
+
+	// 创建PikPak离线下载器，只使用本地下载器时可以不配置PikPak
+	var downloader *OfflineDownloader
+	if config.Pikpak.User != "" {
+		downloader, err = NewOfflineDownloaderWithConfig(config)
+		if err != nil {
+			log.Fatalf("❌ 创建下载器失败: %v", err)
+		}
+
+		// 测试连接
+		err = downloader.TestConnection()
+		if err != nil {
+			log.Fatalf("❌ 测试连接失败: %v", err)
+		}
+	}
+
+	// 创建所有下载器
+	downloaders, err := newDownloaders(config, downloader)
 	if err != nil {
 		log.Fatalf("❌ 创建下载器失败: %v", err)
 	}
 
-	// 测试连接
-	err = downloader.TestConnection()
+	// 打开已见项目存储
+	store, err := NewSeenStore(config.DataDir)
 	if err != nil {
-		log.Fatalf("❌ 测试连接失败: %v", err)
+		log.Fatalf("❌ 打开已见项目存储失败: %v", err)
+	}
+	defer store.Close()
+
+	// 加载订阅，并叠加通过聊天命令做的修改
+	subscriptions, err := loadSubscriptions(config)
+	if err != nil {
+		log.Fatalf("❌ 加载订阅失败: %v", err)
+	}
+	overrides, err := loadSubscriptionOverrides(config.DataDir)
+	if err != nil {
+		log.Fatalf("❌ 加载订阅失败: %v", err)
+	}
+	fromConfig := make(map[*Subscription]bool, len(subscriptions))
+	for _, sub := range subscriptions {
+		fromConfig[sub] = true
+	}
+	subscriptions, err = overrides.apply(subscriptions)
+	if err != nil {
+		log.Fatalf("❌ 加载订阅失败: %v", err)
 	}
 
 	// 创建番剧监听器
 	monitor := &BangumiMonitor{
-		config:      downloader.config,
-		downloader:  downloader,
-		seenItems:   make(map[string]bool),
-		lastChecked: time.Now().Add(-24 * time.Hour), // 从24小时前开始检查
+		config:        config,
+		downloader:    downloader,
+		downloaders:   downloaders,
+		store:         store,
+		subscriptions: subscriptions,
+		overrides:     overrides,
+		lastChecked:   time.Now().Add(-24 * time.Hour), // 从24小时前开始检查
+		feedErrors:    make(map[string]bool),
+	}
+
+	// 检查每个订阅选择的下载器都已配置
+	// 通过命令新增的订阅只跳过，避免下载器配置改变后 subscriptions.json 导致无法启动
+	monitor.subscriptions = subscriptions[:0:0]
+	for _, sub := range subscriptions {
+		if _, err := monitor.downloaderFor(sub); err != nil {
+			if fromConfig[sub] {
+				log.Fatalf("❌ %v", err)
+			}
+			log.Printf("⚠️  跳过通过命令添加的订阅: %v", err)
+			continue
+		}
+		monitor.subscriptions = append(monitor.subscriptions, sub)
 	}
 
-	// 如果配置了Telegram通知，初始化通知器
-	if monitor.config.Telegram.Token != "" && monitor.config.Telegram.ChatID != 0 {
-		monitor.telegramNotifier = NewTelegramNotifier(
-			monitor.config.Telegram.Token,
-			monitor.config.Telegram.ChatID,
-		)
-		log.Printf("✅ Telegram通知已启用")
+	// 创建通知渠道
+	monitor.notifier, err = NewNotificationDispatcher(config)
+	if err != nil {
+		log.Fatalf("❌ 创建通知渠道失败: %v", err)
 	}
 
-	// 显示配置信息
-	monitor.showConfig()
+	// 有渠道需要发送图片时才查询番剧海报
+	for _, notifier := range monitor.notifier.Notifiers() {
+		if images, ok := notifier.(ImageNotifier); ok && images.WantsImages() {
+			monitor.posters = NewBangumiPosters()
+			break
+		}
+	}
 
-	// 初始化已见项目
-	monitor.initializeSeenItems()
+	// 创建任务跟踪器
+	monitor.tracker, err = NewTaskTracker(downloaders, monitor.config, monitor.onTaskFinished)
+	if err != nil {
+		log.Fatalf("❌ 创建任务跟踪器失败: %v", err)
+	}
+
+	// 收到SIGINT/SIGTERM时优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// 后台跟踪任务状态
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		monitor.tracker.Run(ctx)
+	}()
+
+	// 按批量发送、限速和免打扰配置发送通知
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		monitor.notifier.Run(ctx)
+	}()
+
+	// 接收聊天命令，运行需要后台处理的通知渠道
+	for _, notifier := range monitor.notifier.Notifiers() {
+		if listener, ok := notifier.(CommandListener); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				listener.Listen(ctx, monitor)
+			}()
+		}
+		if background, ok := notifier.(BackgroundNotifier); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				background.Run(ctx)
+			}()
+		}
+	}
 
 	// 开始监听
-	monitor.StartMonitoring()
+	monitor.StartMonitoring(ctx)
+
+	// 等待任务跟踪器结束当前轮询
+	wg.Wait()
 }
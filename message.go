@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 消息格式，决定使用哪套内置模板以及如何转义字段
+const (
+	formatText       = "text"
+	formatMarkdown   = "markdown" // Telegram旧版Markdown
+	formatMarkdownV2 = "markdownv2"
+	formatHTML       = "html"
+	formatCommonMark = "commonmark" // 标准Markdown，用于企业微信、钉钉、飞书机器人和Discord
+	formatSlack      = "slack"      // Slack的mrkdwn
+)
+
+// markdownV2Replacer 转义MarkdownV2中所有有特殊含义的字符
+var markdownV2Replacer = strings.NewReplacer(
+	`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+	"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+	"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+)
+
+// formatEscapers 各消息格式的转义函数，模板中的所有字段和函数输出都会经过转义
+var formatEscapers = map[string]func(string) string{
+	formatText:       func(s string) string { return s },
+	formatMarkdown:   strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`).Replace,
+	formatMarkdownV2: markdownV2Replacer.Replace,
+	formatHTML:       html.EscapeString,
+	// 方括号后面不跟链接时按原样显示，番剧标题中很常见，不转义
+	formatCommonMark: strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`").Replace,
+	// Slack不支持反斜杠转义，只需要转义控制字符
+	formatSlack: strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace,
+}
+
+// defaultTemplates 内置的消息模板，格式 -> 事件类型 -> 模板
+var defaultTemplates = map[string]map[string]string{
+	formatText: {
+		EventNewRelease:   "🎬 新番剧下载通知\n\n📺 标题: {{.Title}}\n📁 文件名: {{.FileName}}\n⏰ 时间: {{time .Time}}",
+		EventTaskComplete: "✅ 番剧下载完成\n\n📺 标题: {{.Title}}\n📁 文件名: {{.FileName}}\n💾 大小: {{size .FileSize}}\n⏱️ 耗时: {{duration .Duration}}",
+		EventTaskFailed:   "❌ 番剧下载失败\n\n📺 标题: {{.Title}}\n📁 文件名: {{.FileName}}\n⚠️ 原因: {{.Message}}\n🔄 重试次数: {{.Retries}}\n⏱️ 耗时: {{duration .Duration}}",
+		EventFeedError:    "📡 RSS源获取失败\n\n📋 订阅: {{.Subscription}}\n🔗 地址: {{.Feed}}\n⚠️ 原因: {{.Message}}\n⏰ 时间: {{time .Time}}",
+		EventQuotaWarning: "💾 PikPak存储空间不足\n\n📊 已用: {{size .QuotaUsage}} / {{size .QuotaLimit}} ({{.QuotaPercent}}%)\n⏰ 时间: {{time .Time}}",
+		EventSummary:      "📦 通知汇总（{{len .Events}} 条）\n{{range .Events}}\n{{icon .Type}} {{brief .}}{{end}}",
+	},
+	formatMarkdown: {
+		EventNewRelease:   "🎬 *新番剧下载通知*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n⏰ *时间:* {{time .Time}}",
+		EventTaskComplete: "✅ *番剧下载完成*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n💾 *大小:* {{size .FileSize}}\n⏱️ *耗时:* {{duration .Duration}}",
+		EventTaskFailed:   "❌ *番剧下载失败*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n⚠️ *原因:* {{.Message}}\n🔄 *重试次数:* {{.Retries}}\n⏱️ *耗时:* {{duration .Duration}}",
+		EventFeedError:    "📡 *RSS源获取失败*\n\n📋 *订阅:* {{.Subscription}}\n🔗 *地址:* {{.Feed}}\n⚠️ *原因:* {{.Message}}\n⏰ *时间:* {{time .Time}}",
+		EventQuotaWarning: "💾 *PikPak存储空间不足*\n\n📊 *已用:* {{size .QuotaUsage}} / {{size .QuotaLimit}} ({{.QuotaPercent}}%)\n⏰ *时间:* {{time .Time}}",
+		EventSummary:      "📦 *通知汇总*（{{len .Events}} 条）\n{{range .Events}}\n{{icon .Type}} {{brief .}}{{end}}",
+	},
+	formatMarkdownV2: {
+		EventNewRelease:   "🎬 *新番剧下载通知*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n⏰ *时间:* {{time .Time}}",
+		EventTaskComplete: "✅ *番剧下载完成*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n💾 *大小:* {{size .FileSize}}\n⏱️ *耗时:* {{duration .Duration}}",
+		EventTaskFailed:   "❌ *番剧下载失败*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n⚠️ *原因:* {{.Message}}\n🔄 *重试次数:* {{.Retries}}\n⏱️ *耗时:* {{duration .Duration}}",
+		EventFeedError:    "📡 *RSS源获取失败*\n\n📋 *订阅:* {{.Subscription}}\n🔗 *地址:* {{.Feed}}\n⚠️ *原因:* {{.Message}}\n⏰ *时间:* {{time .Time}}",
+		EventQuotaWarning: "💾 *PikPak存储空间不足*\n\n📊 *已用:* {{size .QuotaUsage}} / {{size .QuotaLimit}} \\({{.QuotaPercent}}%\\)\n⏰ *时间:* {{time .Time}}",
+		EventSummary:      "📦 *通知汇总*（{{len .Events}} 条）\n{{range .Events}}\n{{icon .Type}} {{brief .}}{{end}}",
+	},
+	formatCommonMark: {
+		EventNewRelease:   "🎬 **新番剧下载通知**\n\n📺 **标题:** {{.Title}}\n📁 **文件名:** {{.FileName}}\n⏰ **时间:** {{time .Time}}",
+		EventTaskComplete: "✅ **番剧下载完成**\n\n📺 **标题:** {{.Title}}\n📁 **文件名:** {{.FileName}}\n💾 **大小:** {{size .FileSize}}\n⏱️ **耗时:** {{duration .Duration}}",
+		EventTaskFailed:   "❌ **番剧下载失败**\n\n📺 **标题:** {{.Title}}\n📁 **文件名:** {{.FileName}}\n⚠️ **原因:** {{.Message}}\n🔄 **重试次数:** {{.Retries}}\n⏱️ **耗时:** {{duration .Duration}}",
+		EventFeedError:    "📡 **RSS源获取失败**\n\n📋 **订阅:** {{.Subscription}}\n🔗 **地址:** {{.Feed}}\n⚠️ **原因:** {{.Message}}\n⏰ **时间:** {{time .Time}}",
+		EventQuotaWarning: "💾 **PikPak存储空间不足**\n\n📊 **已用:** {{size .QuotaUsage}} / {{size .QuotaLimit}} ({{.QuotaPercent}}%)\n⏰ **时间:** {{time .Time}}",
+		EventSummary:      "📦 **通知汇总**（{{len .Events}} 条）\n{{range .Events}}\n{{icon .Type}} {{brief .}}{{end}}",
+	},
+	formatSlack: {
+		EventNewRelease:   "🎬 *新番剧下载通知*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n⏰ *时间:* {{time .Time}}",
+		EventTaskComplete: "✅ *番剧下载完成*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n💾 *大小:* {{size .FileSize}}\n⏱️ *耗时:* {{duration .Duration}}",
+		EventTaskFailed:   "❌ *番剧下载失败*\n\n📺 *标题:* {{.Title}}\n📁 *文件名:* {{.FileName}}\n⚠️ *原因:* {{.Message}}\n🔄 *重试次数:* {{.Retries}}\n⏱️ *耗时:* {{duration .Duration}}",
+		EventFeedError:    "📡 *RSS源获取失败*\n\n📋 *订阅:* {{.Subscription}}\n🔗 *地址:* {{.Feed}}\n⚠️ *原因:* {{.Message}}\n⏰ *时间:* {{time .Time}}",
+		EventQuotaWarning: "💾 *PikPak存储空间不足*\n\n📊 *已用:* {{size .QuotaUsage}} / {{size .QuotaLimit}} ({{.QuotaPercent}}%)\n⏰ *时间:* {{time .Time}}",
+		EventSummary:      "📦 *通知汇总*（{{len .Events}} 条）\n{{range .Events}}\n{{icon .Type}} {{brief .}}{{end}}",
+	},
+	formatHTML: {
+		EventNewRelease:   "🎬 <b>新番剧下载通知</b>\n\n📺 <b>标题:</b> {{.Title}}\n📁 <b>文件名:</b> {{.FileName}}\n⏰ <b>时间:</b> {{time .Time}}",
+		EventTaskComplete: "✅ <b>番剧下载完成</b>\n\n📺 <b>标题:</b> {{.Title}}\n📁 <b>文件名:</b> {{.FileName}}\n💾 <b>大小:</b> {{size .FileSize}}\n⏱️ <b>耗时:</b> {{duration .Duration}}",
+		EventTaskFailed:   "❌ <b>番剧下载失败</b>\n\n📺 <b>标题:</b> {{.Title}}\n📁 <b>文件名:</b> {{.FileName}}\n⚠️ <b>原因:</b> {{.Message}}\n🔄 <b>重试次数:</b> {{.Retries}}\n⏱️ <b>耗时:</b> {{duration .Duration}}",
+		EventFeedError:    "📡 <b>RSS源获取失败</b>\n\n📋 <b>订阅:</b> {{.Subscription}}\n🔗 <b>地址:</b> {{.Feed}}\n⚠️ <b>原因:</b> {{.Message}}\n⏰ <b>时间:</b> {{time .Time}}",
+		EventQuotaWarning: "💾 <b>PikPak存储空间不足</b>\n\n📊 <b>已用:</b> {{size .QuotaUsage}} / {{size .QuotaLimit}} ({{.QuotaPercent}}%)\n⏰ <b>时间:</b> {{time .Time}}",
+		EventSummary:      "📦 <b>通知汇总</b>（{{len .Events}} 条）\n{{range .Events}}\n{{icon .Type}} {{brief .}}{{end}}",
+	},
+}
+
+// eventIcons 汇总消息中各事件的图标
+var eventIcons = map[string]string{
+	EventNewRelease:   "🎬",
+	EventTaskComplete: "✅",
+	EventTaskFailed:   "❌",
+	EventFeedError:    "📡",
+	EventQuotaWarning: "💾",
+}
+
+// templateFuncs 模板中可用的函数，输出经过 escape 转义
+func templateFuncs(escape func(string) string) template.FuncMap {
+	return template.FuncMap{
+		"size":     func(size int64) string { return escape(formatSize(size)) },
+		"duration": func(d time.Duration) string { return escape(formatDuration(d)) },
+		"time": func(t time.Time) string {
+			return escape(t.Format("2006-01-02 15:04:05"))
+		},
+		"escape": escape,
+		"join":   strings.Join,
+		"icon":   func(eventType string) string { return eventIcons[eventType] },
+		"brief":  func(event *Event) string { return briefEvent(event, escape) },
+	}
+}
+
+// briefEvent 汇总消息中单个事件的一行描述，event 的文本字段已经转义过
+func briefEvent(event *Event, escape func(string) string) string {
+	name := event.FileName
+	if name == "" {
+		name = event.Title
+	}
+
+	switch event.Type {
+	case EventTaskComplete:
+		return name + escape(" ("+formatSize(event.FileSize)+")")
+	case EventTaskFailed:
+		return name + escape(": ") + event.Message
+	case EventFeedError:
+		return event.Subscription + escape(": ") + event.Message
+	case EventQuotaWarning:
+		return escape(fmt.Sprintf("存储空间已用 %d%%", event.QuotaPercent()))
+	default:
+		return name
+	}
+}
+
+// sampleEvent 用于校验模板的示例事件
+var sampleEvent = &Event{
+	Title:        "[LoliHouse] Sousou no Frieren - 12 [WebRip 1080p HEVC-10bit AAC][简繁内封字幕]",
+	FileName:     "Sousou no Frieren - 12",
+	Subscription: "葬送的芙莉莲",
+	Release:      ParseRelease("[LoliHouse] Sousou no Frieren - 12 [WebRip 1080p HEVC-10bit AAC][简繁内封字幕]"),
+	Downloader:   pikpakDownloaderName,
+	TaskID:       "VOxxxxxxxx",
+	Feed:         "https://mikanani.me/RSS/Bangumi",
+	Time:         time.Now(),
+}
+
+// MessageRenderer 按事件类型渲染消息，未自定义的事件使用内置模板
+type MessageRenderer struct {
+	escape    func(string) string
+	templates map[string]*template.Template
+	fallback  map[string]*template.Template // 内置模板，自定义模板执行失败时使用
+}
+
+// NewMessageRenderer 编译内置模板和自定义模板，overrides 为事件类型 -> 模板
+func NewMessageRenderer(format string, overrides map[string]string) (*MessageRenderer, error) {
+	defaults, ok := defaultTemplates[format]
+	if !ok {
+		return nil, fmt.Errorf("未知的消息格式: %s", format)
+	}
+	for eventType := range overrides {
+		if _, ok := defaults[eventType]; !ok {
+			return nil, fmt.Errorf("未知的事件类型: %s", eventType)
+		}
+	}
+
+	renderer := &MessageRenderer{
+		escape:    formatEscapers[format],
+		templates: make(map[string]*template.Template),
+		fallback:  make(map[string]*template.Template),
+	}
+	funcs := templateFuncs(renderer.escape)
+
+	for _, eventType := range templateEventTypes {
+		renderer.fallback[eventType] = template.Must(template.New(eventType).Funcs(funcs).Parse(defaults[eventType]))
+
+		source, ok := overrides[eventType]
+		if !ok || source == "" {
+			renderer.templates[eventType] = renderer.fallback[eventType]
+			continue
+		}
+
+		tmpl, err := template.New(eventType).Funcs(funcs).Option("missingkey=error").Parse(source)
+		if err != nil {
+			return nil, fmt.Errorf("事件 %s 的模板无效: %v", eventType, err)
+		}
+
+		// 字段名写错只有执行时才会报错，用示例数据提前检查
+		sample := *sampleEvent
+		sample.Type = eventType
+		if eventType == EventSummary {
+			nested := *sampleEvent
+			nested.Type = EventNewRelease
+			sample.Events = []*Event{&nested}
+		}
+		if err := tmpl.Execute(io.Discard, escapeEvent(&sample, renderer.escape)); err != nil {
+			return nil, fmt.Errorf("事件 %s 的模板无效: %v", eventType, err)
+		}
+
+		renderer.templates[eventType] = tmpl
+	}
+
+	return renderer, nil
+}
+
+// Render 渲染事件消息，自定义模板执行失败时退回内置模板
+func (mr *MessageRenderer) Render(event *Event) string {
+	tmpl, ok := mr.templates[event.Type]
+	if !ok {
+		return mr.escape(event.Title)
+	}
+
+	data := escapeEvent(event, mr.escape)
+
+	var sb strings.Builder
+	err := tmpl.Execute(&sb, data)
+	if err == nil {
+		return sb.String()
+	}
+	log.Printf("⚠️  渲染 %s 消息失败，使用内置模板: %v", event.Type, err)
+
+	sb.Reset()
+	if err := mr.fallback[event.Type].Execute(&sb, data); err != nil {
+		return mr.escape(event.Title)
+	}
+	return sb.String()
+}
+
+// escapeEvent 返回所有文本字段都已转义的事件副本
+// 事件类型是程序内部的标识，不转义，模板中可以直接用来比较或查找图标
+func escapeEvent(event *Event, escape func(string) string) *Event {
+	escaped := *event
+	for _, field := range []*string{
+		&escaped.Title, &escaped.FileName, &escaped.Subscription, &escaped.Downloader,
+		&escaped.TaskID, &escaped.FolderID, &escaped.Message, &escaped.Feed, &escaped.ImageURL,
+	} {
+		*field = escape(*field)
+	}
+
+	if event.Release != nil {
+		release := *event.Release
+		for _, field := range []*string{
+			&release.Title, &release.Group, &release.Series, &release.Resolution, &release.Codec, &release.Source,
+		} {
+			*field = escape(*field)
+		}
+		release.Aliases = escapeAll(release.Aliases, escape)
+		release.Languages = escapeAll(release.Languages, escape)
+		escaped.Release = &release
+	}
+
+	if event.Events != nil {
+		escaped.Events = make([]*Event, len(event.Events))
+		for i, nested := range event.Events {
+			escaped.Events[i] = escapeEvent(nested, escape)
+		}
+	}
+
+	return &escaped
+}
+
+// messageField 卡片类消息中单独显示的字段
+type messageField struct {
+	Name  string
+	Value string
+}
+
+// releaseFields 事件中标题解析出的字幕组、集数和分辨率，没有识别出的字段不显示
+func releaseFields(event *Event) []messageField {
+	if event.Release == nil {
+		return nil
+	}
+
+	var fields []messageField
+	for _, field := range []messageField{
+		{"字幕组", event.Release.Group},
+		{"集数", event.Release.EpisodeString()},
+		{"分辨率", event.Release.Resolution},
+	} {
+		if field.Value != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// escapeAll 转义字符串列表
+func escapeAll(values []string, escape func(string) string) []string {
+	if values == nil {
+		return nil
+	}
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = escape(v)
+	}
+	return escaped
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 通知事件类型
+const (
+	EventNewRelease   = "new_release"
+	EventTaskComplete = "task_complete"
+	EventTaskFailed   = "task_failed"
+	EventFeedError    = "feed_error"
+	EventQuotaWarning = "quota_warning"
+	// EventSummary 合并多个事件的汇总消息，由批量发送、限速和免打扰产生，渠道不能单独订阅
+	EventSummary = "summary"
+)
+
+// allEventTypes 所有事件类型，用于校验配置
+var allEventTypes = []string{EventNewRelease, EventTaskComplete, EventTaskFailed, EventFeedError, EventQuotaWarning}
+
+// templateEventTypes 有消息模板的事件类型
+var templateEventTypes = append(append([]string(nil), allEventTypes...), EventSummary)
+
+// Event 通知事件，包含发送通知所需的全部信息，也是消息模板的数据
+type Event struct {
+	Type         string
+	Title        string // RSS中的原始标题
+	FileName     string
+	Subscription string
+	Release      *Release
+	Downloader   string
+	TaskID       string
+	FolderID     string
+	FileSize     int64
+	Duration     time.Duration
+	Retries      int
+	Message      string // 失败原因
+	Feed         string // RSS源地址
+	ImageURL     string // RSS项目封面或番剧海报，只在有渠道开启 images 时获取
+	QuotaUsage   int64
+	QuotaLimit   int64
+	SubmittedAt  time.Time // 任务提交时间
+	Events       []*Event  // 汇总消息中合并的事件
+	Time         time.Time
+}
+
+// QuotaPercent 存储空间使用百分比
+func (e *Event) QuotaPercent() int {
+	return quotaPercent(e.QuotaUsage, e.QuotaLimit)
+}
+
+// quotaPercent 计算使用百分比
+func quotaPercent(usage, limit int64) int {
+	if limit <= 0 {
+		return 0
+	}
+	return int(usage * 100 / limit)
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	// Name 渠道名称，用于日志
+	Name() string
+	// Send 发送一条事件通知
+	Send(event *Event) error
+}
+
+// BackgroundNotifier 需要在后台运行的通知渠道，如定时发送汇总的邮件
+type BackgroundNotifier interface {
+	// Run 在后台运行直到 ctx 取消，退出前应发送完缓存的通知
+	Run(ctx context.Context)
+}
+
+// ImageNotifier 可以在消息中附带图片的通知渠道
+type ImageNotifier interface {
+	// WantsImages 是否需要获取RSS项目封面或番剧海报
+	WantsImages() bool
+}
+
+// TargetNotifier 向多个目标（如多个地址、多个用户和群）发送的通知渠道
+// 部分目标失败时 Send 返回 *TargetError，重试时只发送到失败的目标
+type TargetNotifier interface {
+	// SendTo 只向 targets 中的目标发送，已不在配置中的目标被忽略
+	SendTo(event *Event, targets []string) error
+}
+
+// TargetError 部分目标发送失败
+type TargetError struct {
+	Failed []string // 发送失败的目标
+	Total  int      // 本次发送的目标数量
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("%d/%d 个目标发送失败: %v", len(e.Failed), e.Total, e.Failed)
+}
+
+// filterTargets configured 中在 targets 里的目标，保持配置中的顺序
+func filterTargets(configured, targets []string) []string {
+	wanted := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		wanted[target] = true
+	}
+
+	var filtered []string
+	for _, target := range configured {
+		if wanted[target] {
+			filtered = append(filtered, target)
+		}
+	}
+	return filtered
+}
+
+// notifierTypes 通知渠道类型 -> 构造函数，新增渠道只需在这里注册
+// 构造函数通过 NotifierConfig.decodeOptions 解析渠道自己的配置项
+var notifierTypes = map[string]func(config NotifierConfig) (Notifier, error){
+	"qq":         newQQNotifier,
+	"telegram":   newTelegramNotifier,
+	"webhook":    newWebhookNotifier,
+	"bark":       newBarkNotifier,
+	"serverchan": newServerChanNotifier,
+	"ntfy":       newNtfyNotifier,
+	"gotify":     newGotifyNotifier,
+	"pushplus":   newPushPlusNotifier,
+	"wecom":      newWeComNotifier,
+	"dingtalk":   newDingTalkNotifier,
+	"feishu":     newFeishuNotifier,
+	"lark":       newFeishuNotifier,
+	"discord":    newDiscordNotifier,
+	"slack":      newSlackNotifier,
+	"email":      newEmailNotifier,
+}
+
+// registeredNotifier 已配置的通知渠道及其事件过滤
+type registeredNotifier struct {
+	notifier Notifier
+	events   map[string]bool    // 为空时接收所有事件
+	queue    *notificationQueue // 配置了批量发送、限速或免打扰时经过队列发送
+}
+
+// accepts 判断渠道是否订阅了该事件
+func (rn *registeredNotifier) accepts(eventType string) bool {
+	return len(rn.events) == 0 || rn.events[eventType]
+}
+
+// NotificationDispatcher 将事件并发分发到所有订阅了该事件的渠道，单个渠道失败不影响其他渠道
+// 发送失败的通知放入 outbox 稍后重试
+type NotificationDispatcher struct {
+	notifiers []*registeredNotifier
+	outbox    *Outbox
+}
+
+// notifierConfigs 汇总所有通知渠道配置
+// 旧的 qq、telegram 配置块会作为隐式渠道，接收所有事件
+func notifierConfigs(config *Config) []NotifierConfig {
+	configs := append([]NotifierConfig(nil), config.Notifiers...)
+
+	if config.QQ.Enabled && config.QQ.BotURL != "" {
+		configs = append(configs, NotifierConfig{
+			Name: "qq",
+			Type: "qq",
+			Options: notifierOptions(QQOptions{
+				URL:      config.QQ.BotURL,
+				Token:    config.QQ.Token,
+				Users:    config.QQ.NotifyUsers,
+				Groups:   config.QQ.NotifyGroups,
+				Mentions: config.QQ.Mentions,
+				Images:   config.QQ.Images,
+				Listen:   config.QQ.Listen,
+				Secret:   config.QQ.Secret,
+			}),
+		})
+	}
+
+	if config.Telegram.Enabled && config.Telegram.Token != "" && config.Telegram.ChatID != 0 {
+		configs = append(configs, NotifierConfig{
+			Name: "telegram",
+			Type: "telegram",
+			Options: notifierOptions(TelegramOptions{
+				Token:        config.Telegram.Token,
+				ChatID:       config.Telegram.ChatID,
+				ParseMode:    config.Telegram.ParseMode,
+				Commands:     config.Telegram.Commands,
+				AllowedChats: config.Telegram.AllowedChats,
+			}),
+		})
+	}
+
+	return configs
+}
+
+// NewNotificationDispatcher 根据配置创建所有通知渠道
+func NewNotificationDispatcher(config *Config) (*NotificationDispatcher, error) {
+	outbox, err := NewOutbox(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dispatcher := &NotificationDispatcher{outbox: outbox}
+	names := make(map[string]bool)
+
+	for i, nc := range notifierConfigs(config) {
+		nc.Type = strings.ToLower(nc.Type)
+		nc.Templates = mergeTemplates(config.Templates, nc.Templates)
+		nc.DataDir = config.DataDir
+		if nc.Name == "" {
+			nc.Name = nc.Type
+		}
+		if names[nc.Name] {
+			return nil, fmt.Errorf("通知渠道名称重复: %s", nc.Name)
+		}
+		names[nc.Name] = true
+
+		newNotifier, ok := notifierTypes[nc.Type]
+		if !ok {
+			return nil, fmt.Errorf("第 %d 个通知渠道的类型不支持: %s", i+1, nc.Type)
+		}
+
+		events, err := parseEventFilter(nc.Events)
+		if err != nil {
+			return nil, fmt.Errorf("通知渠道 [%s] 的 events 无效: %v", nc.Name, err)
+		}
+
+		if _, err := NewMessageRenderer(formatText, nc.Templates); err != nil {
+			return nil, fmt.Errorf("通知渠道 [%s] 的 templates 无效: %v", nc.Name, err)
+		}
+
+		// 渠道创建失败（如Bot无法连接）只跳过该渠道，不影响程序启动
+		notifier, err := newNotifier(nc)
+		if err != nil {
+			log.Printf("❌ 创建通知渠道 [%s] 失败: %v", nc.Name, err)
+			continue
+		}
+
+		queue, err := newNotificationQueue(notifier, nc)
+		if err != nil {
+			return nil, fmt.Errorf("通知渠道 [%s] 的配置无效: %v", nc.Name, err)
+		}
+		if queue != nil {
+			queue.outbox = outbox
+		}
+
+		dispatcher.notifiers = append(dispatcher.notifiers, &registeredNotifier{notifier: notifier, events: events, queue: queue})
+		log.Printf("✅ 已配置通知渠道: %s (%s)", nc.Name, nc.Type)
+	}
+
+	return dispatcher, nil
+}
+
+// mergeTemplates 合并全局模板和渠道模板，渠道模板优先
+func mergeTemplates(global, channel map[string]string) map[string]string {
+	merged := make(map[string]string, len(global)+len(channel))
+	for eventType, tmpl := range global {
+		merged[eventType] = tmpl
+	}
+	for eventType, tmpl := range channel {
+		merged[eventType] = tmpl
+	}
+	return merged
+}
+
+// parseEventFilter 解析渠道订阅的事件类型
+func parseEventFilter(events []string) (map[string]bool, error) {
+	filter := make(map[string]bool)
+	for _, event := range events {
+		valid := false
+		for _, known := range allEventTypes {
+			if event == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("未知的事件类型: %s", event)
+		}
+		filter[event] = true
+	}
+	return filter, nil
+}
+
+// Notifiers 返回所有通知渠道
+func (nd *NotificationDispatcher) Notifiers() []Notifier {
+	notifiers := make([]Notifier, 0, len(nd.notifiers))
+	for _, rn := range nd.notifiers {
+		notifiers = append(notifiers, rn.notifier)
+	}
+	return notifiers
+}
+
+// Run 运行各渠道的发送队列和失败重试，ctx 取消时发送完队列中剩余的事件后返回
+func (nd *NotificationDispatcher) Run(ctx context.Context) {
+	notifiers := make(map[string]Notifier, len(nd.notifiers))
+	for _, rn := range nd.notifiers {
+		notifiers[rn.notifier.Name()] = rn.notifier
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		nd.outbox.Run(ctx, notifiers)
+	}()
+
+	for _, rn := range nd.notifiers {
+		if rn.queue == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(queue *notificationQueue) {
+			defer wg.Done()
+			queue.Run(ctx)
+		}(rn.queue)
+	}
+	wg.Wait()
+}
+
+// Dispatch 将事件发送到所有订阅了该事件的渠道，直接发送的渠道并发发送并等待完成，使用队列的渠道只加入队列
+func (nd *NotificationDispatcher) Dispatch(event *Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	var wg sync.WaitGroup
+	for _, rn := range nd.notifiers {
+		if !rn.accepts(event.Type) {
+			continue
+		}
+		if rn.queue != nil {
+			rn.queue.Push(event)
+			continue
+		}
+
+		wg.Add(1)
+		go func(notifier Notifier) {
+			defer wg.Done()
+			if err := deliverEvent(notifier, event, nil); err != nil {
+				nd.outbox.Add(notifier.Name(), event, err)
+			}
+		}(rn.notifier)
+	}
+	wg.Wait()
+}
+
+// deliverEvent 通过渠道发送事件并记录日志，panic 也作为发送失败返回
+// targets 不为空时只发送到这些目标（重试部分失败的通知）
+func deliverEvent(notifier Notifier, event *Event, targets []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ 通知渠道 [%s] 发生异常: %v", notifier.Name(), r)
+			err = fmt.Errorf("发生异常: %v", r)
+		}
+	}()
+
+	subject := event.FileName
+	if event.Type == EventSummary {
+		subject = fmt.Sprintf("%d 条通知的汇总", len(event.Events))
+	} else if subject == "" {
+		subject = event.Type
+	}
+
+	send := notifier.Send
+	if tn, ok := notifier.(TargetNotifier); ok && len(targets) > 0 {
+		send = func(event *Event) error { return tn.SendTo(event, targets) }
+	}
+
+	if err := send(event); err != nil {
+		log.Printf("❌ 发送通知失败 [%s]: %v", notifier.Name(), err)
+		return err
+	}
+	log.Printf("✅ 通知发送成功 [%s]: %s", notifier.Name(), subject)
+	return nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/net/websocket"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oneBotMaxBodySize HTTP上报和WebSocket消息的最大长度
+const oneBotMaxBodySize = 1 << 20
+
+// oneBotCQUnescaper 还原CQ码中转义的字符，磁力链接中的 & 会被转义为 &amp;
+var oneBotCQUnescaper = strings.NewReplacer("&#91;", "[", "&#93;", "]", "&#44;", ",", "&amp;", "&")
+
+// OneBotEvent OneBot v11 上报的事件，只解析处理消息需要的字段
+type OneBotEvent struct {
+	PostType    string `json:"post_type"` // message / notice / request / meta_event
+	MessageType string `json:"message_type"`
+	UserID      int64  `json:"user_id"`
+	RawMessage  string `json:"raw_message"` // CQ码格式的消息
+}
+
+// oneBotAction 通过反向WebSocket调用的OneBot API
+type oneBotAction struct {
+	Action string      `json:"action"`
+	Params interface{} `json:"params"`
+	Echo   string      `json:"echo,omitempty"`
+}
+
+// Listen 在 listen 地址上接收OneBot事件，同时支持HTTP上报和反向WebSocket
+// 没有配置 token 和 secret 时无法校验事件来源，不会启动监听
+func (qn *QQNotifier) Listen(ctx context.Context, handler CommandHandler) {
+	if qn.listen == "" {
+		return
+	}
+	if qn.bot.Token == "" && qn.secret == "" {
+		log.Printf("❌ QQ命令未启用 [%s]: 配置了listen时必须配置token或secret", qn.name)
+		return
+	}
+
+	server := &http.Server{
+		Addr:    qn.listen,
+		Handler: qn.eventHandler(ctx, handler),
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("🤖 QQ命令已启用 [%s]: 监听 %s", qn.name, qn.listen)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("❌ OneBot事件监听失败 [%s]: %v", qn.name, err)
+		return
+	}
+	log.Printf("👋 QQ命令已停止 [%s]", qn.name)
+}
+
+// eventHandler 接收OneBot事件的HTTP处理器，WebSocket升级请求按反向WebSocket处理，其他按HTTP上报处理
+func (qn *QQNotifier) eventHandler(ctx context.Context, handler CommandHandler) http.Handler {
+	ws := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			// OneBot客户端不会发送Origin，只校验access_token
+			if !qn.authorized(r) {
+				return fmt.Errorf("access_token 无效")
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			qn.serveWebSocket(ctx, handler, conn)
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		qn.serveHTTPPost(ctx, handler, w, r)
+	})
+}
+
+// authorized 校验请求中的access_token，未配置token时总是失败
+func (qn *QQNotifier) authorized(r *http.Request) bool {
+	token := qn.bot.Token
+	if token == "" {
+		return false
+	}
+
+	auth := r.Header.Get("Authorization")
+	for _, prefix := range []string{"Bearer ", "Token "} {
+		if strings.HasPrefix(auth, prefix) {
+			auth = strings.TrimPrefix(auth, prefix)
+			break
+		}
+	}
+	if auth == "" {
+		auth = r.URL.Query().Get("access_token")
+	}
+	return hmac.Equal([]byte(auth), []byte(token))
+}
+
+// serveHTTPPost 处理HTTP上报的事件，需要有效的 X-Signature 或 access_token，通过HTTP API回复
+func (qn *QQNotifier) serveHTTPPost(ctx context.Context, handler CommandHandler, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, oneBotMaxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "request entity too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if !qn.validSignature(r, body) && !qn.authorized(r) {
+		log.Printf("🚫 OneBot上报的签名或access_token无效 [%s]: %s", qn.name, r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var event OneBotEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// 不使用快速操作，命令可能比较慢（如检查），超过OneBot的上报超时
+	w.WriteHeader(http.StatusNoContent)
+
+	go qn.handleEvent(ctx, handler, &event, func(userID, message string) error {
+		_, err := qn.bot.SendPrivateMessage(userID, []QQMessageSegment{QQText(message)})
+		return err
+	})
+}
+
+// validSignature 校验HTTP上报的 X-Signature，未配置 secret 时总是失败
+func (qn *QQNotifier) validSignature(r *http.Request, body []byte) bool {
+	if qn.secret == "" {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, []byte(qn.secret))
+	mac.Write(body)
+	expected := "sha1=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(r.Header.Get("X-Signature")), []byte(expected))
+}
+
+// serveWebSocket 处理反向WebSocket连接，事件和回复都通过该连接传输
+func (qn *QQNotifier) serveWebSocket(ctx context.Context, handler CommandHandler, conn *websocket.Conn) {
+	defer conn.Close()
+	conn.MaxPayloadBytes = oneBotMaxBodySize
+
+	log.Printf("🔌 OneBot已连接 [%s]: %s", qn.name, conn.Request().RemoteAddr)
+
+	// 程序退出时断开连接，结束读取
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	var writeMutex sync.Mutex
+	reply := func(userID, message string) error {
+		id, err := strconv.ParseInt(userID, 10, 64)
+		if err != nil {
+			return fmt.Errorf("无效的QQ号: %s", userID)
+		}
+
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+		return websocket.JSON.Send(conn, oneBotAction{
+			Action: "send_private_msg",
+			Params: map[string]interface{}{"user_id": id, "message": message, "auto_escape": true},
+			Echo:   fmt.Sprintf("reply-%d", time.Now().UnixNano()),
+		})
+	}
+
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("🔌 OneBot连接已断开 [%s]: %v", qn.name, err)
+			}
+			return
+		}
+
+		var event OneBotEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			log.Printf("⚠️  无法解析OneBot消息: %v", err)
+			continue
+		}
+
+		// 没有 post_type 的是API调用结果
+		if event.PostType == "" {
+			var response QQResponse
+			if err := json.Unmarshal(data, &response); err == nil && response.err() != nil {
+				log.Printf("❌ 回复QQ命令失败: %v", response.err())
+			}
+			continue
+		}
+
+		go qn.handleEvent(ctx, handler, &event, reply)
+	}
+}
+
+// handleEvent 处理私聊消息事件，只接受 users 中的用户发送的命令
+func (qn *QQNotifier) handleEvent(ctx context.Context, handler CommandHandler, event *OneBotEvent, reply func(userID, message string) error) {
+	if event.PostType != "message" || event.MessageType != "private" {
+		return
+	}
+
+	userID := strconv.FormatInt(event.UserID, 10)
+	if !qn.allowedUsers[userID] {
+		log.Printf("🚫 忽略未授权用户的QQ消息 (用户: %s)", userID)
+		return
+	}
+
+	cmd, ok := parseCommandText(oneBotCQUnescaper.Replace(event.RawMessage))
+	if !ok {
+		return
+	}
+	cmd.From = "qq:" + userID
+
+	if err := reply(userID, handler.HandleCommand(ctx, cmd)); err != nil {
+		log.Printf("❌ 回复QQ命令失败 (用户: %s): %v", userID, err)
+	}
+}
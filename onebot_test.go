@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// fakeCommandHandler 记录收到的命令，回复固定内容
+type fakeCommandHandler struct {
+	commands chan *Command
+}
+
+func (h *fakeCommandHandler) HandleCommand(ctx context.Context, cmd *Command) string {
+	h.commands <- cmd
+	return "pong " + cmd.Name
+}
+
+func (h *fakeCommandHandler) HandleAction(ctx context.Context, action, taskID string) (string, error) {
+	return "", nil
+}
+
+// oneBotStandIn 本地的OneBot HTTP API，记录 send_private_msg 请求
+func oneBotStandIn(t *testing.T) (*httptest.Server, chan map[string]interface{}) {
+	t.Helper()
+	requests := make(chan map[string]interface{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/send_private_msg" {
+			t.Errorf("unexpected OneBot API call: %s", r.URL.Path)
+		}
+		var params map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&params)
+		requests <- params
+		w.Write([]byte(`{"status":"ok","retcode":0,"data":{"message_id":1}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func newTestQQNotifier(url, token, secret string) *QQNotifier {
+	return &QQNotifier{
+		name:         "qq",
+		bot:          NewQQBot(url, token),
+		users:        []string{"10001"},
+		allowedUsers: map[string]bool{"10001": true},
+		listen:       ":0",
+		secret:       secret,
+	}
+}
+
+func oneBotMessage(userID int64, text string) []byte {
+	data, _ := json.Marshal(OneBotEvent{PostType: "message", MessageType: "private", UserID: userID, RawMessage: text})
+	return data
+}
+
+func oneBotSignature(secret string, body []byte) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestOneBotHTTPPostAuth(t *testing.T) {
+	body := oneBotMessage(10001, "/tasks")
+
+	tests := []struct {
+		name   string
+		token  string
+		secret string
+		header map[string]string
+		query  string
+		want   int
+	}{
+		{name: "no credentials configured", want: http.StatusUnauthorized},
+		{name: "missing signature", secret: "s3cret", want: http.StatusUnauthorized},
+		{name: "bad signature", secret: "s3cret", header: map[string]string{"X-Signature": oneBotSignature("wrong", body)}, want: http.StatusUnauthorized},
+		{name: "valid signature", secret: "s3cret", header: map[string]string{"X-Signature": oneBotSignature("s3cret", body)}, want: http.StatusNoContent},
+		{name: "missing token", token: "t0ken", want: http.StatusUnauthorized},
+		{name: "wrong token", token: "t0ken", header: map[string]string{"Authorization": "Bearer nope"}, want: http.StatusUnauthorized},
+		{name: "bearer token", token: "t0ken", header: map[string]string{"Authorization": "Bearer t0ken"}, want: http.StatusNoContent},
+		{name: "query token", token: "t0ken", query: "?access_token=t0ken", want: http.StatusNoContent},
+		{name: "token with only secret configured", secret: "s3cret", header: map[string]string{"Authorization": "Bearer "}, want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qn := newTestQQNotifier("http://127.0.0.1:1", tt.token, tt.secret)
+			handler := &fakeCommandHandler{commands: make(chan *Command, 1)}
+
+			req := httptest.NewRequest(http.MethodPost, "/"+tt.query, bytes.NewReader(body))
+			for key, value := range tt.header {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			qn.eventHandler(context.Background(), handler).ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestOneBotHTTPPostTooLarge(t *testing.T) {
+	qn := newTestQQNotifier("http://127.0.0.1:1", "t0ken", "")
+	handler := &fakeCommandHandler{commands: make(chan *Command, 1)}
+
+	body := bytes.Repeat([]byte(" "), oneBotMaxBodySize+1)
+	req := httptest.NewRequest(http.MethodPost, "/?access_token=t0ken", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	qn.eventHandler(context.Background(), handler).ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestOneBotHTTPPostReply(t *testing.T) {
+	standIn, requests := oneBotStandIn(t)
+	qn := newTestQQNotifier(standIn.URL, "", "s3cret")
+	handler := &fakeCommandHandler{commands: make(chan *Command, 1)}
+
+	server := httptest.NewServer(qn.eventHandler(context.Background(), handler))
+	defer server.Close()
+
+	post := func(body []byte) int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/", bytes.NewReader(body))
+		req.Header.Set("X-Signature", oneBotSignature("s3cret", body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// 未授权用户的消息被忽略
+	if status := post(oneBotMessage(20002, "/tasks")); status != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", status, http.StatusNoContent)
+	}
+	if status := post(oneBotMessage(10001, "任务")); status != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", status, http.StatusNoContent)
+	}
+
+	select {
+	case cmd := <-handler.commands:
+		if cmd.Name != "tasks" || cmd.From != "qq:10001" {
+			t.Fatalf("command = %+v, want tasks from qq:10001", cmd)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("command not handled")
+	}
+
+	select {
+	case params := <-requests:
+		if params["user_id"] != "10001" {
+			t.Errorf("user_id = %v, want 10001", params["user_id"])
+		}
+		if !strings.Contains(string(mustJSON(t, params["message"])), "pong tasks") {
+			t.Errorf("message = %v, want reply text", params["message"])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reply not sent to OneBot")
+	}
+
+	select {
+	case cmd := <-handler.commands:
+		t.Fatalf("unexpected command %+v", cmd)
+	default:
+	}
+}
+
+func TestOneBotReverseWebSocket(t *testing.T) {
+	qn := newTestQQNotifier("http://127.0.0.1:1", "t0ken", "")
+	handler := &fakeCommandHandler{commands: make(chan *Command, 1)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := httptest.NewServer(qn.eventHandler(ctx, handler))
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/onebot/v11/ws"
+	dial := func(token string) (*websocket.Conn, error) {
+		config, err := websocket.NewConfig(wsURL, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			config.Header.Set("Authorization", "Bearer "+token)
+		}
+		return websocket.DialConfig(config)
+	}
+
+	if conn, err := dial(""); err == nil {
+		conn.Close()
+		t.Fatal("dial without token succeeded")
+	}
+
+	conn, err := dial("t0ken")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	if err := websocket.Message.Send(conn, string(oneBotMessage(10001, "/quota"))); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var action struct {
+		Action string `json:"action"`
+		Params struct {
+			UserID  int64  `json:"user_id"`
+			Message string `json:"message"`
+		} `json:"params"`
+	}
+	if err := websocket.JSON.Receive(conn, &action); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if action.Action != "send_private_msg" || action.Params.UserID != 10001 || action.Params.Message != "pong quota" {
+		t.Fatalf("action = %+v, want send_private_msg to 10001", action)
+	}
+}
+
+func TestOneBotListenRequiresCredentials(t *testing.T) {
+	qn := newTestQQNotifier("http://127.0.0.1:1", "", "")
+	handler := &fakeCommandHandler{commands: make(chan *Command, 1)}
+
+	done := make(chan struct{})
+	go func() {
+		qn.Listen(context.Background(), handler)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Listen started without token or secret")
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
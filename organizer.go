@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"github.com/lyqingye/pikpak-go"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	// defaultFolderTemplate 默认的目录结构，相对于整理根目录
+	defaultFolderTemplate = `{{.Series}}/Season {{printf "%02d" .Season}}`
+	// defaultNameTemplate 默认的文件名（不含扩展名）
+	defaultNameTemplate = `{{.Series}} - S{{printf "%02d" .Season}}E{{printf "%02d" .Episode}}{{if .Resolution}} [{{.Resolution}}]{{end}}`
+)
+
+// videoExtensions 视为视频文件的扩展名
+var videoExtensions = map[string]bool{
+	".mkv": true, ".mp4": true, ".avi": true, ".ts": true, ".m2ts": true, ".mov": true, ".webm": true,
+}
+
+// invalidPathChars 文件名中不允许出现的字符
+var invalidPathChars = regexp.MustCompile(`[<>:"/\\|?*]`)
+
+// OrganizeData 命名模板可用的字段
+type OrganizeData struct {
+	Series     string
+	Season     int
+	Episode    int
+	Version    int
+	Resolution string
+	Group      string
+	Codec      string
+	Source     string
+	Title      string
+}
+
+// compileOrganizeTemplates 编译订阅的整理模板
+func (sub *Subscription) compileOrganizeTemplates() error {
+	if !sub.Organize.Enabled {
+		return nil
+	}
+
+	folderTemplate := sub.Organize.FolderTemplate
+	if folderTemplate == "" {
+		folderTemplate = defaultFolderTemplate
+	}
+	nameTemplate := sub.Organize.NameTemplate
+	if nameTemplate == "" {
+		nameTemplate = defaultNameTemplate
+	}
+
+	var err error
+	sub.folderTmpl, err = template.New("folder").Option("missingkey=error").Parse(folderTemplate)
+	if err != nil {
+		return fmt.Errorf("订阅 [%s] 的 folder_template 无效: %v", sub.Name, err)
+	}
+	sub.nameTmpl, err = template.New("name").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return fmt.Errorf("订阅 [%s] 的 name_template 无效: %v", sub.Name, err)
+	}
+
+	// 字段名写错只有执行时才会报错，用示例数据提前检查
+	sample := OrganizeData{Series: "Series", Season: 1, Episode: 1, Version: 1}
+	if err := sub.folderTmpl.Execute(io.Discard, sample); err != nil {
+		return fmt.Errorf("订阅 [%s] 的 folder_template 无效: %v", sub.Name, err)
+	}
+	if err := sub.nameTmpl.Execute(io.Discard, sample); err != nil {
+		return fmt.Errorf("订阅 [%s] 的 name_template 无效: %v", sub.Name, err)
+	}
+
+	return nil
+}
+
+// findSubscription 按名称查找订阅
+func (bm *BangumiMonitor) findSubscription(name string) *Subscription {
+	for _, sub := range bm.subscriptionList() {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// organizeTask 下载完成后将视频移动到 <根目录>/<番剧名>/Season 01/ 并按模板重命名
+func (bm *BangumiMonitor) organizeTask(result *TaskResult) {
+	task := result.Task
+
+	sub := bm.findSubscription(task.Subscription)
+	if sub == nil || !sub.Organize.Enabled {
+		return
+	}
+	if task.downloaderName() != pikpakDownloaderName || bm.downloader == nil {
+		log.Printf("⚠️  整理功能仅支持PikPak下载器，跳过: %s", task.FileName)
+		return
+	}
+	if result.FileID == "" {
+		log.Printf("⚠️  任务没有文件ID，跳过整理: %s", task.FileName)
+		return
+	}
+
+	release := ParseRelease(task.Title)
+	if release.Batch || release.Episode == 0 {
+		log.Printf("⚠️  无法确定集数或为合集，跳过整理: %s", task.Title)
+		return
+	}
+
+	data := OrganizeData{
+		Series:     release.Series,
+		Season:     release.Season,
+		Episode:    release.Episode,
+		Version:    release.Version,
+		Resolution: release.Resolution,
+		Group:      release.Group,
+		Codec:      release.Codec,
+		Source:     release.Source,
+		Title:      task.Title,
+	}
+	if sub.Organize.SeriesName != "" {
+		data.Series = sub.Organize.SeriesName
+	}
+	if sub.Organize.Season > 0 {
+		data.Season = sub.Organize.Season
+	}
+	if data.Season == 0 {
+		data.Season = 1
+	}
+	if data.Series == "" {
+		log.Printf("⚠️  无法确定番剧名，跳过整理: %s", task.Title)
+		return
+	}
+
+	video, err := bm.findVideoFile(result.FileID)
+	if err != nil {
+		log.Printf("❌ 查找视频文件失败: %v", err)
+		return
+	}
+
+	folderID, folderPath, err := bm.ensureOrganizeFolder(sub, data)
+	if err != nil {
+		log.Printf("❌ 创建整理目录失败: %v", err)
+		return
+	}
+
+	var name strings.Builder
+	if err := sub.nameTmpl.Execute(&name, data); err != nil {
+		log.Printf("❌ 生成文件名失败: %v", err)
+		return
+	}
+	newName := sanitizePathSegment(name.String()) + strings.ToLower(path.Ext(video.Name))
+
+	if video.ParentID != folderID {
+		if err := bm.downloader.MoveFile(video.ID, folderID); err != nil {
+			log.Printf("❌ %v", err)
+			return
+		}
+	}
+	if video.Name != newName {
+		if err := bm.downloader.RenameFile(video.ID, newName); err != nil {
+			log.Printf("❌ %v", err)
+			return
+		}
+	}
+
+	log.Printf("🗂️  已整理: %s -> %s/%s", video.Name, folderPath, newName)
+}
+
+// findVideoFile 找到任务对应的视频文件，任务是文件夹时取其中最大的视频
+func (bm *BangumiMonitor) findVideoFile(fileID string) (*pikpakgo.File, error) {
+	file, err := bm.downloader.GetFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.Kind != pikpakgo.KindOfFolder {
+		return file, nil
+	}
+
+	var largest *pikpakgo.File
+	queue := []string{file.ID}
+	for len(queue) > 0 {
+		files, err := bm.downloader.GetFileList(queue[0])
+		if err != nil {
+			return nil, err
+		}
+		queue = queue[1:]
+
+		for _, f := range files {
+			if f.Kind == pikpakgo.KindOfFolder {
+				queue = append(queue, f.ID)
+				continue
+			}
+			if videoExtensions[strings.ToLower(path.Ext(f.Name))] && (largest == nil || f.Size > largest.Size) {
+				largest = f
+			}
+		}
+	}
+
+	if largest == nil {
+		return nil, fmt.Errorf("文件夹中没有视频文件: %s", file.Name)
+	}
+	return largest, nil
+}
+
+// ensureOrganizeFolder 按目录模板逐级创建整理目录，返回最终目录的ID和路径
+func (bm *BangumiMonitor) ensureOrganizeFolder(sub *Subscription, data OrganizeData) (string, string, error) {
+	root := sub.Organize.RootPath
+	if root == "" {
+		root = sub.FolderPath
+	}
+	if root == "" {
+		root = bm.config.Pikpak.FolderPath
+	}
+	if root == "" {
+		root = "/"
+	}
+
+	folderID, err := bm.downloader.ResolveFolderPath(root)
+	if err != nil {
+		return "", "", err
+	}
+
+	var rel strings.Builder
+	if err := sub.folderTmpl.Execute(&rel, data); err != nil {
+		return "", "", fmt.Errorf("生成目录失败: %v", err)
+	}
+
+	folderPath := strings.TrimSuffix(root, "/")
+	for _, segment := range strings.Split(rel.String(), "/") {
+		segment = sanitizePathSegment(segment)
+		if segment == "" {
+			continue
+		}
+
+		folderID, err = bm.downloader.EnsureSubFolder(folderID, segment)
+		if err != nil {
+			return "", "", err
+		}
+		folderPath += "/" + segment
+	}
+
+	return folderID, folderPath, nil
+}
+
+// sanitizePathSegment 替换文件名中的非法字符
+func sanitizePathSegment(name string) string {
+	name = invalidPathChars.ReplaceAllString(name, "_")
+	name = strings.Join(strings.Fields(name), " ")
+	return strings.Trim(name, " .")
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// outboxStoreFile 待重试通知的保存文件
+const outboxStoreFile = "outbox.json"
+
+// outboxPollInterval 检查到期重试的间隔
+var outboxPollInterval = 10 * time.Second
+
+// OutboxMessage 发送失败、等待重试的通知
+type OutboxMessage struct {
+	ID          string    `json:"id"`
+	Notifier    string    `json:"notifier"` // 通知渠道名称
+	Event       *Event    `json:"event"`
+	Targets     []string  `json:"targets,omitempty"` // 只重试这些目标，为空时重试整个渠道
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	NextAttempt time.Time `json:"next_attempt"`
+	// DeferredUntil 暂不发送的通知（如免打扰时段）的发送时间，最长保留时间从这时开始计算
+	DeferredUntil time.Time `json:"deferred_until,omitzero"`
+}
+
+// age 通知已等待的时间，暂不发送的通知从计划发送的时间开始计算
+func (m *OutboxMessage) age(now time.Time) time.Duration {
+	since := m.CreatedAt
+	if m.DeferredUntil.After(since) {
+		since = m.DeferredUntil
+	}
+	return now.Sub(since)
+}
+
+// Outbox 发送失败的通知按指数退避重试，保存在数据目录中，重启后继续重试
+// 超过最长保留时间仍未发送成功的通知会被放弃
+type Outbox struct {
+	path       string
+	retryDelay time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	maxDelay   time.Duration
+	maxAge     time.Duration
+	messages   map[string]*OutboxMessage
+	lastID     int64
+	mutex      sync.Mutex
+}
+
+// NewOutbox 创建重试队列，并加载上次未发送的通知
+func NewOutbox(config *Config) (*Outbox, error) {
+	dataDir := config.DataDir
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建数据目录失败: %v", err)
+	}
+
+	retryDelay := time.Duration(config.Outbox.RetryDelaySeconds) * time.Second
+	if retryDelay == 0 {
+		retryDelay = 30 * time.Second
+	}
+
+	maxDelay := time.Duration(config.Outbox.MaxDelayMinutes) * time.Minute
+	if maxDelay == 0 {
+		maxDelay = time.Hour
+	}
+
+	maxAge := time.Duration(config.Outbox.MaxAgeHours) * time.Hour
+	if maxAge == 0 {
+		maxAge = 24 * time.Hour
+	}
+
+	outbox := &Outbox{
+		path:       filepath.Join(dataDir, outboxStoreFile),
+		retryDelay: retryDelay,
+		maxDelay:   maxDelay,
+		maxAge:     maxAge,
+		messages:   make(map[string]*OutboxMessage),
+	}
+
+	if err := outbox.load(); err != nil {
+		return nil, err
+	}
+
+	if len(outbox.messages) > 0 {
+		log.Printf("📮 恢复 %d 条待重试的通知", len(outbox.messages))
+	}
+
+	return outbox, nil
+}
+
+// load 读取保存的通知
+func (o *Outbox) load() error {
+	data, err := os.ReadFile(o.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取通知重试文件失败: %v", err)
+	}
+
+	var messages []*OutboxMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("解析通知重试文件失败: %v", err)
+	}
+
+	for _, message := range messages {
+		o.messages[message.ID] = message
+	}
+	return nil
+}
+
+// save 保存通知列表，调用方需持有锁
+func (o *Outbox) save() error {
+	messages := make([]*OutboxMessage, 0, len(o.messages))
+	for _, message := range o.messages {
+		messages = append(messages, message)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(messages, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码通知列表失败: %v", err)
+	}
+
+	// 先写临时文件再重命名，避免写到一半被中断
+	tmp := o.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入通知重试文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, o.path); err != nil {
+		return fmt.Errorf("写入通知重试文件失败: %v", err)
+	}
+	return nil
+}
+
+// Add 加入一条发送失败的通知，等待重试，部分目标失败时只重试失败的目标
+func (o *Outbox) Add(notifier string, event *Event, sendErr error) {
+	now := time.Now()
+	o.put(&OutboxMessage{
+		Notifier:    notifier,
+		Event:       event,
+		Targets:     failedTargets(sendErr, nil),
+		Attempts:    1,
+		LastError:   sendErr.Error(),
+		CreatedAt:   now,
+		NextAttempt: now.Add(o.retryDelay),
+	})
+	log.Printf("📮 通知将在 %v 后重试 [%s]", o.retryDelay, notifier)
+}
+
+// Defer 加入一条暂不发送的通知，到 at 时再发送（如免打扰时段中暂存的通知）
+func (o *Outbox) Defer(notifier string, event *Event, at time.Time) {
+	o.put(&OutboxMessage{
+		Notifier:      notifier,
+		Event:         event,
+		CreatedAt:     time.Now(),
+		NextAttempt:   at,
+		DeferredUntil: at,
+	})
+}
+
+// put 保存一条新通知
+func (o *Outbox) put(message *OutboxMessage) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	// ID 按时间生成，同一纳秒内的顺延
+	id := message.CreatedAt.UnixNano()
+	if id <= o.lastID {
+		id = o.lastID + 1
+	}
+	o.lastID = id
+	message.ID = strconv.FormatInt(id, 36)
+
+	o.messages[message.ID] = message
+	if err := o.save(); err != nil {
+		log.Printf("⚠️  保存待重试的通知失败: %v", err)
+	}
+}
+
+// Len 待重试的通知数量
+func (o *Outbox) Len() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.messages)
+}
+
+// Run 定期重试到期的通知，notifiers 为渠道名称 -> 渠道
+func (o *Outbox) Run(ctx context.Context, notifiers map[string]Notifier) {
+	// 启动时先重试一次，处理上次退出前未发送的通知
+	o.retry(notifiers)
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.retry(notifiers)
+		}
+	}
+}
+
+// retry 按加入顺序重试所有到期的通知
+func (o *Outbox) retry(notifiers map[string]Notifier) {
+	now := time.Now()
+
+	o.mutex.Lock()
+	var due []*OutboxMessage
+	for _, message := range o.messages {
+		if !message.NextAttempt.After(now) {
+			due = append(due, message)
+		}
+	}
+	o.mutex.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+
+	for _, message := range due {
+		notifier, ok := notifiers[message.Notifier]
+		if !ok {
+			o.drop(message, "通知渠道已不存在")
+			continue
+		}
+		if message.age(now) > o.maxAge {
+			o.drop(message, fmt.Sprintf("超过最长保留时间 %v", o.maxAge))
+			continue
+		}
+
+		err := deliverEvent(notifier, message.Event, message.Targets)
+
+		o.mutex.Lock()
+		if err == nil {
+			delete(o.messages, message.ID)
+		} else {
+			message.Targets = failedTargets(err, message.Targets)
+			message.Attempts++
+			message.LastError = err.Error()
+			message.NextAttempt = time.Now().Add(o.backoff(message.Attempts))
+		}
+		if err := o.save(); err != nil {
+			log.Printf("⚠️  保存待重试的通知失败: %v", err)
+		}
+		o.mutex.Unlock()
+	}
+}
+
+// failedTargets 发送失败的目标，不是部分目标失败时返回 previous
+func failedTargets(err error, previous []string) []string {
+	var targetErr *TargetError
+	if errors.As(err, &targetErr) {
+		return targetErr.Failed
+	}
+	return previous
+}
+
+// backoff 第 attempts 次失败后的等待时间，从 retryDelay 开始每次翻倍，不超过 maxDelay
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.retryDelay
+	for i := 1; i < attempts && delay < o.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > o.maxDelay {
+		delay = o.maxDelay
+	}
+	return delay
+}
+
+// drop 放弃一条通知
+func (o *Outbox) drop(message *OutboxMessage, reason string) {
+	subject := message.Event.FileName
+	if subject == "" {
+		subject = message.Event.Type
+	}
+
+	lastError := message.LastError
+	if lastError == "" {
+		lastError = "无"
+	}
+	log.Printf("🗑️ 放弃发送通知 [%s]: %s（%s，已尝试 %d 次，最后错误: %s）",
+		message.Notifier, subject, reason, message.Attempts, lastError)
+
+	o.mutex.Lock()
+	delete(o.messages, message.ID)
+	if err := o.save(); err != nil {
+		log.Printf("⚠️  保存待重试的通知失败: %v", err)
+	}
+	o.mutex.Unlock()
+}
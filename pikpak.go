@@ -6,14 +6,18 @@ import (
 	"github.com/lyqingye/pikpak-go"
 	"io/ioutil"
 	"log"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // OfflineDownloader 离线下载器结构体
 type OfflineDownloader struct {
-	client *pikpakgo.PikPakClient
-	config *Config
+	client      *pikpakgo.PikPakClient
+	config      *Config
+	folderCache map[string]string // 文件夹路径 -> 文件夹ID
+	folderMutex sync.Mutex
 }
 
 // NewOfflineDownloader 创建新的离线下载器实例
@@ -23,6 +27,11 @@ func NewOfflineDownloader(configFile string) (*OfflineDownloader, error) {
 		return nil, fmt.Errorf("加载配置失败: %v", err)
 	}
 
+	return NewOfflineDownloaderWithConfig(config)
+}
+
+// NewOfflineDownloaderWithConfig 使用已加载的配置创建离线下载器
+func NewOfflineDownloaderWithConfig(config *Config) (*OfflineDownloader, error) {
 	client, err := pikpakgo.NewPikPakClient(config.Pikpak.User, config.Pikpak.Passwd)
 	if err != nil {
 		return nil, fmt.Errorf("创建PikPak客户端失败: %v", err)
@@ -37,8 +46,9 @@ func NewOfflineDownloader(configFile string) (*OfflineDownloader, error) {
 	log.Printf("✅ PikPak登录成功: %s", config.Pikpak.User)
 
 	downloader := &OfflineDownloader{
-		client: client,
-		config: config,
+		client:      client,
+		config:      config,
+		folderCache: make(map[string]string),
 	}
 
 	// 初始化目标文件夹
@@ -81,10 +91,46 @@ func (od *OfflineDownloader) TestConnection() error {
 	return nil
 }
 
-// AddMagnetTask 添加磁力链接下载任务
-func (od *OfflineDownloader) AddMagnetTask(fileName, magnetLink string) error {
+// Name 下载器名称
+func (od *OfflineDownloader) Name() string {
+	return pikpakDownloaderName
+}
+
+// ResolveFolder 获取目录路径对应的文件夹ID，路径为空时使用默认文件夹
+func (od *OfflineDownloader) ResolveFolder(folderPath string) (string, error) {
+	if folderPath == "" {
+		return od.getTargetFolderID(), nil
+	}
+	return od.ResolveFolderPath(folderPath)
+}
+
+// AddTask 添加下载任务到指定文件夹ID
+func (od *OfflineDownloader) AddTask(name, link, folder string) (string, error) {
+	return od.AddMagnetTaskToFolder(name, link, folder)
+}
+
+// Quota 获取存储空间的已用和总量（字节）
+func (od *OfflineDownloader) Quota() (int64, int64, error) {
 	if od.client == nil {
-		return fmt.Errorf("客户端未初始化")
+		return 0, 0, fmt.Errorf("客户端未初始化")
+	}
+
+	about, err := od.client.About()
+	if err != nil {
+		return 0, 0, fmt.Errorf("获取存储信息失败: %v", err)
+	}
+	return about.Quota.Usage, about.Quota.Limit, nil
+}
+
+// AddMagnetTask 添加磁力链接下载任务到默认文件夹，返回PikPak任务ID
+func (od *OfflineDownloader) AddMagnetTask(fileName, magnetLink string) (string, error) {
+	return od.AddMagnetTaskToFolder(fileName, magnetLink, od.getTargetFolderID())
+}
+
+// AddMagnetTaskToFolder 添加磁力链接下载任务到指定文件夹，返回PikPak任务ID
+func (od *OfflineDownloader) AddMagnetTaskToFolder(fileName, magnetLink, targetFolderID string) (string, error) {
+	if od.client == nil {
+		return "", fmt.Errorf("客户端未初始化")
 	}
 
 	log.Printf("📥 开始添加离线下载任务: %s", fileName)
@@ -98,8 +144,6 @@ func (od *OfflineDownloader) AddMagnetTask(fileName, magnetLink string) error {
 		log.Printf("🔗 下载链接: %s", magnetLink)
 	}
 
-	// 获取目标文件夹ID
-	targetFolderID := od.getTargetFolderID()
 	if targetFolderID != "" {
 		log.Printf("📁 目标文件夹ID: %s", targetFolderID)
 	} else {
@@ -110,10 +154,12 @@ func (od *OfflineDownloader) AddMagnetTask(fileName, magnetLink string) error {
 	// PikPak支持磁力链接和种子文件链接
 	newTask, err := od.client.OfflineDownload(fileName, magnetLink, targetFolderID)
 	if err != nil {
-		return fmt.Errorf("添加离线下载任务失败: %v", err)
+		return "", fmt.Errorf("添加离线下载任务失败: %v", err)
 	}
 
+	taskID := ""
 	if newTask != nil && newTask.Task != nil {
+		taskID = newTask.Task.ID
 		log.Printf("✅ 离线下载任务添加成功")
 		log.Printf("   📋 任务ID: %s", newTask.Task.ID)
 		log.Printf("   📁 文件名: %s", fileName)
@@ -121,7 +167,7 @@ func (od *OfflineDownloader) AddMagnetTask(fileName, magnetLink string) error {
 		log.Printf("   📂 目标文件夹: %s", targetFolderID)
 	}
 
-	return nil
+	return taskID, nil
 }
 
 // GetTaskStatus 获取任务状态
@@ -142,7 +188,7 @@ func (od *OfflineDownloader) GetTaskStatus(taskId string) (*pikpakgo.Task, error
 		if task.ID == taskId {
 			log.Printf("✅ 找到任务: %s", task.Name)
 			log.Printf("   📊 状态: %s", task.Phase)
-			log.Printf("   📈 进度: %.2f%%", task.Progress*100)
+			log.Printf("   📈 进度: %d%%", task.Progress)
 			return task, nil
 		}
 	}
@@ -150,21 +196,34 @@ func (od *OfflineDownloader) GetTaskStatus(taskId string) (*pikpakgo.Task, error
 	return nil, fmt.Errorf("未找到任务: %s", taskId)
 }
 
+// GetTask 查询单个任务，排队中的任务不会出现在任务列表里
+func (od *OfflineDownloader) GetTask(taskId string) (*DownloadTask, error) {
+	found, err := od.FindTasks(map[string]bool{taskId: true})
+	if err != nil {
+		return nil, err
+	}
+	task, ok := found[taskId]
+	if !ok {
+		return nil, fmt.Errorf("未找到任务: %s", taskId)
+	}
+	return task, nil
+}
+
 // ListTasks 列出所有任务
-func (od *OfflineDownloader) ListTasks() ([]*pikpakgo.Task, error) {
+func (od *OfflineDownloader) ListTasks() ([]*DownloadTask, error) {
 	if od.client == nil {
 		return nil, fmt.Errorf("客户端未初始化")
 	}
 
 	log.Printf("📋 获取任务列表...")
 
-	var allTasks []*pikpakgo.Task
+	var allTasks []*DownloadTask
 
 	// 使用迭代器获取所有任务
 	err := od.client.OfflineListIterator(func(task *pikpakgo.Task) bool {
-		allTasks = append(allTasks, task)
-		log.Printf("   📄 %s - %s (%.1f%%)", task.Name, task.Phase, task.Progress*100)
-		return true // 继续迭代
+		allTasks = append(allTasks, pikpakDownloadTask(task))
+		log.Printf("   📄 %s - %s (%d%%)", task.Name, task.Phase, task.Progress)
+		return false // 返回true会停止迭代
 	})
 
 	if err != nil {
@@ -175,6 +234,53 @@ func (od *OfflineDownloader) ListTasks() ([]*pikpakgo.Task, error) {
 	return allTasks, nil
 }
 
+// FindTasks 查询指定任务的当前状态，未出现在任务列表中的任务不会包含在结果里
+func (od *OfflineDownloader) FindTasks(taskIds map[string]bool) (map[string]*DownloadTask, error) {
+	if od.client == nil {
+		return nil, fmt.Errorf("客户端未初始化")
+	}
+
+	found := make(map[string]*DownloadTask)
+	err := od.client.OfflineListIterator(func(task *pikpakgo.Task) bool {
+		if taskIds[task.ID] {
+			found[task.ID] = pikpakDownloadTask(task)
+		}
+		return len(found) == len(taskIds)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取任务列表失败: %v", err)
+	}
+
+	return found, nil
+}
+
+// pikpakDownloadTask 将PikPak离线任务转换为统一的任务
+func pikpakDownloadTask(task *pikpakgo.Task) *DownloadTask {
+	size, _ := strconv.ParseInt(task.FileSize, 10, 64)
+
+	phase := TaskPhaseRunning
+	switch task.Phase {
+	case pikpakgo.PhaseTypeComplete:
+		phase = TaskPhaseComplete
+	case pikpakgo.PhaseTypeError:
+		phase = TaskPhaseError
+	case pikpakgo.PhaseTypePending:
+		phase = TaskPhasePending
+	}
+
+	return &DownloadTask{
+		ID:        task.ID,
+		Name:      task.Name,
+		Phase:     phase,
+		Progress:  task.Progress,
+		FileID:    task.FileID,
+		FileSize:  size,
+		Message:   task.Message,
+		CreatedAt: time.Time(task.CreatedTime),
+		UpdatedAt: time.Time(task.UpdatedTime),
+	}
+}
+
 // RemoveTask 删除任务
 func (od *OfflineDownloader) RemoveTask(taskId string, deleteFiles bool) error {
 	if od.client == nil {
@@ -239,7 +345,7 @@ func (od *OfflineDownloader) WaitForTaskComplete(taskId string, timeout time.Dur
 	log.Printf("⏳ 等待任务完成: %s (超时: %v)", taskId, timeout)
 
 	task, err := od.client.WaitForOfflineDownloadComplete(taskId, timeout, func(task *pikpakgo.Task) {
-		log.Printf("📊 下载进度: %s - %s (%.1f%%)", task.Name, task.Phase, task.Progress*100)
+		log.Printf("📊 下载进度: %s - %s (%d%%)", task.Name, task.Phase, task.Progress)
 	})
 
 	if err != nil {
@@ -263,6 +369,11 @@ func parseJSONFile(filename string) (*Config, error) {
 		return nil, fmt.Errorf("解析JSON失败: %v", err)
 	}
 
+	// 校验订阅配置和过滤规则
+	if _, err := loadSubscriptions(&config); err != nil {
+		return nil, fmt.Errorf("配置校验失败: %v", err)
+	}
+
 	return &config, nil
 }
 
@@ -313,6 +424,94 @@ func (od *OfflineDownloader) initializeTargetFolder() error {
 	return nil
 }
 
+// ResolveFolderPath 获取文件夹路径对应的ID，不存在时自动创建
+func (od *OfflineDownloader) ResolveFolderPath(folderPath string) (string, error) {
+	if od.client == nil {
+		return "", fmt.Errorf("客户端未初始化")
+	}
+
+	od.folderMutex.Lock()
+	defer od.folderMutex.Unlock()
+
+	if folderID, ok := od.folderCache[folderPath]; ok {
+		return folderID, nil
+	}
+
+	log.Printf("📁 获取文件夹路径: %s", folderPath)
+
+	folderID, err := od.client.FolderPathToID(folderPath, true)
+	if err != nil {
+		return "", fmt.Errorf("获取文件夹ID失败: %v", err)
+	}
+
+	od.folderCache[folderPath] = folderID
+	log.Printf("✅ 文件夹ID获取成功: %s -> %s", folderPath, folderID)
+	return folderID, nil
+}
+
+// EnsureSubFolder 获取父文件夹下指定名称的子文件夹ID，不存在时创建
+func (od *OfflineDownloader) EnsureSubFolder(parentID, name string) (string, error) {
+	if od.client == nil {
+		return "", fmt.Errorf("客户端未初始化")
+	}
+
+	files, err := od.client.FileListAll(parentID)
+	if err != nil {
+		return "", fmt.Errorf("获取文件夹内容失败: %v", err)
+	}
+
+	for _, file := range files {
+		if file.Name == name && file.Kind == pikpakgo.KindOfFolder && !file.Trashed {
+			return file.ID, nil
+		}
+	}
+
+	folder, err := od.client.CreateFolder(name, parentID)
+	if err != nil {
+		return "", fmt.Errorf("创建文件夹失败: %v", err)
+	}
+
+	log.Printf("✅ 文件夹创建成功: %s (ID: %s)", name, folder.ID)
+	return folder.ID, nil
+}
+
+// GetFile 获取文件信息
+func (od *OfflineDownloader) GetFile(fileID string) (*pikpakgo.File, error) {
+	if od.client == nil {
+		return nil, fmt.Errorf("客户端未初始化")
+	}
+
+	file, err := od.client.GetFile(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}
+	return file, nil
+}
+
+// MoveFile 移动文件到指定文件夹
+func (od *OfflineDownloader) MoveFile(fileID, folderID string) error {
+	if od.client == nil {
+		return fmt.Errorf("客户端未初始化")
+	}
+
+	if err := od.client.BatchMoveFiles([]string{fileID}, folderID); err != nil {
+		return fmt.Errorf("移动文件失败: %v", err)
+	}
+	return nil
+}
+
+// RenameFile 重命名文件
+func (od *OfflineDownloader) RenameFile(fileID, name string) error {
+	if od.client == nil {
+		return fmt.Errorf("客户端未初始化")
+	}
+
+	if _, err := od.client.RenameFile(fileID, name); err != nil {
+		return fmt.Errorf("重命名文件失败: %v", err)
+	}
+	return nil
+}
+
 // getTargetFolderID 获取目标文件夹ID
 func (od *OfflineDownloader) getTargetFolderID() string {
 	return od.config.Pikpak.FolderID
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 推送服务的默认地址
+const (
+	barkDefaultServer       = "https://api.day.app"
+	serverChanDefaultServer = "https://sctapi.ftqq.com"
+	pushPlusDefaultServer   = "https://www.pushplus.plus"
+)
+
+// serverChanProKeyRegex Server酱³ 的SendKey，推送地址中需要带上其中的uid
+var serverChanProKeyRegex = regexp.MustCompile(`^sctp(\d+)t`)
+
+// pushClient 推送服务共用的HTTP客户端
+var pushClient = &http.Client{Timeout: 15 * time.Second}
+
+// splitTitle 将渲染好的消息拆成标题（第一行）和正文，用于需要单独标题的推送服务
+func splitTitle(message string) (string, string) {
+	title, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	title, body = strings.TrimSpace(title), strings.TrimSpace(body)
+	if body == "" {
+		body = title
+	}
+	return title, body
+}
+
+// pushRequest 发送推送请求，result 不为空时解析返回的JSON
+func pushRequest(req *http.Request, result interface{}) error {
+	resp, err := pushClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送HTTP请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("状态码: %d, 响应: %s", resp.StatusCode, body)
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(body, result); err != nil {
+			return fmt.Errorf("解析响应失败: %s", body)
+		}
+	}
+	return nil
+}
+
+// postJSON 以JSON发送推送请求
+func postJSON(endpoint string, payload interface{}, result interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("JSON编码请求失败: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return pushRequest(req, result)
+}
+
+// serverURL 配置的服务地址，未配置时使用默认地址
+func serverURL(configured, fallback string) string {
+	if configured == "" {
+		configured = fallback
+	}
+	return strings.TrimRight(configured, "/")
+}
+
+// BarkNotifier 通过Bark推送到iOS设备
+type BarkNotifier struct {
+	name     string
+	server   string
+	key      string
+	level    string // active / timeSensitive / passive / critical
+	group    string
+	icon     string
+	renderer *MessageRenderer
+}
+
+// BarkOptions Bark渠道的配置
+type BarkOptions struct {
+	URL     string `json:"url"`   // 服务器地址，默认 https://api.day.app
+	Token   string `json:"token"` // 设备Key
+	Level   string `json:"level"` // active / timeSensitive / passive / critical
+	Group   string `json:"group"`
+	IconURL string `json:"icon_url"`
+}
+
+// newBarkNotifier 根据通知渠道配置创建Bark通知，token 为设备Key
+func newBarkNotifier(config NotifierConfig) (Notifier, error) {
+	var options BarkOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.Token == "" {
+		return nil, fmt.Errorf("缺少token（设备Key）")
+	}
+
+	renderer, err := NewMessageRenderer(formatText, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BarkNotifier{
+		name:     config.Name,
+		server:   serverURL(options.URL, barkDefaultServer),
+		key:      options.Token,
+		level:    options.Level,
+		group:    options.Group,
+		icon:     options.IconURL,
+		renderer: renderer,
+	}, nil
+}
+
+// Name 渠道名称
+func (bn *BarkNotifier) Name() string {
+	return bn.name
+}
+
+// Send 发送推送
+func (bn *BarkNotifier) Send(event *Event) error {
+	title, body := splitTitle(bn.renderer.Render(event))
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err := postJSON(bn.server+"/push", map[string]string{
+		"device_key": bn.key,
+		"title":      title,
+		"body":       body,
+		"level":      bn.level,
+		"group":      bn.group,
+		"icon":       bn.icon,
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if result.Code != http.StatusOK {
+		return fmt.Errorf("Bark返回错误 (code: %d): %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// ServerChanNotifier 通过Server酱（Turbo版或Server酱³）推送到微信
+type ServerChanNotifier struct {
+	name     string
+	endpoint string
+	channel  string
+	renderer *MessageRenderer
+}
+
+// ServerChanOptions Server酱渠道的配置
+type ServerChanOptions struct {
+	URL     string `json:"url"`     // 可选，默认按SendKey选择Turbo版或Server酱³的地址
+	Token   string `json:"token"`   // SendKey
+	Channel string `json:"channel"` // 消息通道
+}
+
+// newServerChanNotifier 根据通知渠道配置创建Server酱通知，token 为SendKey
+func newServerChanNotifier(config NotifierConfig) (Notifier, error) {
+	var options ServerChanOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.Token == "" {
+		return nil, fmt.Errorf("缺少token（SendKey）")
+	}
+
+	renderer, err := NewMessageRenderer(formatText, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := serverURL(options.URL, serverChanDefaultServer) + "/" + options.Token + ".send"
+	if match := serverChanProKeyRegex.FindStringSubmatch(options.Token); match != nil && options.URL == "" {
+		endpoint = fmt.Sprintf("https://%s.push.ft07.com/send/%s.send", match[1], options.Token)
+	}
+
+	return &ServerChanNotifier{
+		name:     config.Name,
+		endpoint: endpoint,
+		channel:  options.Channel,
+		renderer: renderer,
+	}, nil
+}
+
+// Name 渠道名称
+func (sn *ServerChanNotifier) Name() string {
+	return sn.name
+}
+
+// Send 发送推送，正文按Markdown显示，单个换行需要变成空行才会换行
+func (sn *ServerChanNotifier) Send(event *Event) error {
+	title, body := splitTitle(sn.renderer.Render(event))
+
+	form := url.Values{}
+	form.Set("title", title)
+	form.Set("desp", strings.ReplaceAll(body, "\n", "\n\n"))
+	if sn.channel != "" {
+		form.Set("channel", sn.channel)
+	}
+
+	req, err := http.NewRequest("POST", sn.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := pushRequest(req, &result); err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("Server酱返回错误 (code: %d): %s", result.Code, result.Message)
+	}
+	return nil
+}
+
+// NtfyNotifier 通过ntfy推送，url 为完整的主题地址（如 https://ntfy.sh/my-bangumi）
+type NtfyNotifier struct {
+	name     string
+	topicURL string
+	token    string
+	priority string // 1-5 或 min / low / default / high / urgent
+	tags     string
+	icon     string
+	renderer *MessageRenderer
+}
+
+// NtfyOptions ntfy渠道的配置
+type NtfyOptions struct {
+	URL      string `json:"url"`      // 完整的主题地址
+	Token    string `json:"token"`    // 可选，访问令牌
+	Priority string `json:"priority"` // 1-5 或 min / low / default / high / urgent
+	Tags     string `json:"tags"`     // 逗号分隔的标签
+	IconURL  string `json:"icon_url"`
+}
+
+// newNtfyNotifier 根据通知渠道配置创建ntfy通知
+func newNtfyNotifier(config NotifierConfig) (Notifier, error) {
+	var options NtfyOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.URL == "" {
+		return nil, fmt.Errorf("缺少url（主题地址）")
+	}
+
+	renderer, err := NewMessageRenderer(formatText, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &NtfyNotifier{
+		name:     config.Name,
+		topicURL: options.URL,
+		token:    options.Token,
+		priority: options.Priority,
+		tags:     options.Tags,
+		icon:     options.IconURL,
+		renderer: renderer,
+	}, nil
+}
+
+// Name 渠道名称
+func (nn *NtfyNotifier) Name() string {
+	return nn.name
+}
+
+// Send 发送推送，标题等参数通过请求头传递
+func (nn *NtfyNotifier) Send(event *Event) error {
+	title, body := splitTitle(nn.renderer.Render(event))
+
+	req, err := http.NewRequest("POST", nn.topicURL, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+
+	// 请求头只能是ASCII，中文标题使用RFC 2047编码，ntfy会自动解码
+	req.Header.Set("Title", mime.QEncoding.Encode("utf-8", title))
+	for header, value := range map[string]string{"Priority": nn.priority, "Tags": nn.tags, "Icon": nn.icon} {
+		if value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	if nn.token != "" {
+		req.Header.Set("Authorization", "Bearer "+nn.token)
+	}
+
+	return pushRequest(req, nil)
+}
+
+// GotifyNotifier 通过Gotify推送，token 为应用Token
+type GotifyNotifier struct {
+	name     string
+	server   string
+	token    string
+	priority int
+	icon     string
+	renderer *MessageRenderer
+}
+
+// GotifyOptions Gotify渠道的配置
+type GotifyOptions struct {
+	URL      string `json:"url"`      // 服务器地址
+	Token    string `json:"token"`    // 应用Token
+	Priority *int   `json:"priority"` // 默认5
+	IconURL  string `json:"icon_url"` // 通知大图
+}
+
+// newGotifyNotifier 根据通知渠道配置创建Gotify通知
+func newGotifyNotifier(config NotifierConfig) (Notifier, error) {
+	var options GotifyOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.URL == "" || options.Token == "" {
+		return nil, fmt.Errorf("缺少url或token")
+	}
+
+	priority := 5
+	if options.Priority != nil {
+		priority = *options.Priority
+	}
+
+	renderer, err := NewMessageRenderer(formatText, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GotifyNotifier{
+		name:     config.Name,
+		server:   strings.TrimRight(options.URL, "/"),
+		token:    options.Token,
+		priority: priority,
+		icon:     options.IconURL,
+		renderer: renderer,
+	}, nil
+}
+
+// Name 渠道名称
+func (gn *GotifyNotifier) Name() string {
+	return gn.name
+}
+
+// Send 发送推送，icon_url 作为通知的大图显示（Gotify的图标只能在应用上设置）
+func (gn *GotifyNotifier) Send(event *Event) error {
+	title, body := splitTitle(gn.renderer.Render(event))
+
+	payload := map[string]interface{}{
+		"title":    title,
+		"message":  body,
+		"priority": gn.priority,
+	}
+	if gn.icon != "" {
+		payload["extras"] = map[string]interface{}{
+			"client::notification": map[string]string{"bigImageUrl": gn.icon},
+		}
+	}
+
+	return postJSON(gn.server+"/message?token="+url.QueryEscape(gn.token), payload, nil)
+}
+
+// PushPlusNotifier 通过PushPlus推送到微信，topic 为群组编码（一对多推送）
+type PushPlusNotifier struct {
+	name     string
+	server   string
+	token    string
+	topic    string
+	renderer *MessageRenderer
+}
+
+// PushPlusOptions PushPlus渠道的配置
+type PushPlusOptions struct {
+	URL   string `json:"url"` // 可选，默认 https://www.pushplus.plus
+	Token string `json:"token"`
+	Topic string `json:"topic"` // 群组编码
+}
+
+// newPushPlusNotifier 根据通知渠道配置创建PushPlus通知
+func newPushPlusNotifier(config NotifierConfig) (Notifier, error) {
+	var options PushPlusOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.Token == "" {
+		return nil, fmt.Errorf("缺少token")
+	}
+
+	renderer, err := NewMessageRenderer(formatText, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PushPlusNotifier{
+		name:     config.Name,
+		server:   serverURL(options.URL, pushPlusDefaultServer),
+		token:    options.Token,
+		topic:    options.Topic,
+		renderer: renderer,
+	}, nil
+}
+
+// Name 渠道名称
+func (pn *PushPlusNotifier) Name() string {
+	return pn.name
+}
+
+// Send 发送推送
+func (pn *PushPlusNotifier) Send(event *Event) error {
+	title, body := splitTitle(pn.renderer.Render(event))
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	err := postJSON(pn.server+"/send", map[string]string{
+		"token":    pn.token,
+		"title":    title,
+		"content":  body,
+		"template": "txt",
+		"topic":    pn.topic,
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if result.Code != http.StatusOK {
+		return fmt.Errorf("PushPlus返回错误 (code: %d): %s", result.Code, result.Msg)
+	}
+	return nil
+}
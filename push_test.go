@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// pushRequestRecord 推送服务收到的请求
+type pushRequestRecord struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   string
+}
+
+// pushStandIn 本地的推送服务，记录收到的请求并返回固定的状态码和响应
+func pushStandIn(t *testing.T, status int, response string) (*httptest.Server, *pushRequestRecord) {
+	t.Helper()
+	record := &pushRequestRecord{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*record = pushRequestRecord{method: r.Method, path: r.URL.Path, query: r.URL.Query(), header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, record
+}
+
+// newTestNotifier 按配置文件中的写法创建通知渠道
+func newTestNotifier(t *testing.T, config string) Notifier {
+	t.Helper()
+	var notifierConfig NotifierConfig
+	if err := json.Unmarshal([]byte(config), &notifierConfig); err != nil {
+		t.Fatal(err)
+	}
+	notifier, err := notifierTypes[notifierConfig.Type](notifierConfig)
+	if err != nil {
+		t.Fatalf("create %s notifier: %v", notifierConfig.Type, err)
+	}
+	return notifier
+}
+
+func pushTestEvent() *Event {
+	return &Event{Type: EventTaskFailed, Title: "[ANi] 葬送的芙莉蓮 - 12", FileName: "葬送的芙莉蓮 - 12.mp4", Message: "下载超时", Retries: 2}
+}
+
+func decodeJSONBody(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		t.Fatalf("request body is not JSON: %s", body)
+	}
+	return payload
+}
+
+func TestBarkSend(t *testing.T) {
+	server, record := pushStandIn(t, http.StatusOK, `{"code":200,"message":"success"}`)
+	notifier := newTestNotifier(t, `{"name":"bark","type":"bark","url":"`+server.URL+`/","token":"devkey","level":"timeSensitive","group":"番剧","icon_url":"https://example.com/icon.png"}`)
+
+	if err := notifier.Send(pushTestEvent()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if record.method != http.MethodPost || record.path != "/push" {
+		t.Fatalf("request = %s %s, want POST /push", record.method, record.path)
+	}
+	if !strings.HasPrefix(record.header.Get("Content-Type"), "application/json") {
+		t.Errorf("Content-Type = %q", record.header.Get("Content-Type"))
+	}
+	payload := decodeJSONBody(t, record.body)
+	want := map[string]string{"device_key": "devkey", "title": "❌ 番剧下载失败", "level": "timeSensitive", "group": "番剧", "icon": "https://example.com/icon.png"}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("%s = %v, want %q", key, payload[key], value)
+		}
+	}
+	if body, _ := payload["body"].(string); !strings.Contains(body, "下载超时") || strings.Contains(body, "番剧下载失败") {
+		t.Errorf("body = %q, want message without title", body)
+	}
+}
+
+func TestBarkSendErrorCode(t *testing.T) {
+	server, _ := pushStandIn(t, http.StatusOK, `{"code":400,"message":"failed to get device token"}`)
+	notifier := newTestNotifier(t, `{"name":"bark","type":"bark","url":"`+server.URL+`","token":"devkey"}`)
+
+	err := notifier.Send(pushTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "code: 400") {
+		t.Fatalf("Send error = %v, want code 400", err)
+	}
+}
+
+func TestServerChanSend(t *testing.T) {
+	server, record := pushStandIn(t, http.StatusOK, `{"code":0,"message":"","data":{"pushid":"1"}}`)
+	notifier := newTestNotifier(t, `{"name":"serverchan","type":"serverchan","url":"`+server.URL+`","token":"SCT123","channel":"9"}`)
+
+	if err := notifier.Send(pushTestEvent()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if record.method != http.MethodPost || record.path != "/SCT123.send" {
+		t.Fatalf("request = %s %s, want POST /SCT123.send", record.method, record.path)
+	}
+	if record.header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type = %q", record.header.Get("Content-Type"))
+	}
+	form, err := url.ParseQuery(record.body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Get("title") != "❌ 番剧下载失败" || form.Get("channel") != "9" {
+		t.Errorf("form = %v", form)
+	}
+	// 单个换行转为空行，Markdown中才会换行
+	if !strings.Contains(form.Get("desp"), "\n\n📁 文件名") {
+		t.Errorf("desp = %q, want blank lines between lines", form.Get("desp"))
+	}
+}
+
+func TestServerChanSendErrorCode(t *testing.T) {
+	server, _ := pushStandIn(t, http.StatusOK, `{"code":40001,"message":"bad pushkey"}`)
+	notifier := newTestNotifier(t, `{"name":"serverchan","type":"serverchan","url":"`+server.URL+`","token":"SCT123"}`)
+
+	err := notifier.Send(pushTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "code: 40001") {
+		t.Fatalf("Send error = %v, want code 40001", err)
+	}
+}
+
+func TestServerChanProEndpoint(t *testing.T) {
+	notifier := newTestNotifier(t, `{"name":"serverchan","type":"serverchan","token":"sctp1234tabcd"}`)
+
+	want := "https://1234.push.ft07.com/send/sctp1234tabcd.send"
+	if endpoint := notifier.(*ServerChanNotifier).endpoint; endpoint != want {
+		t.Fatalf("endpoint = %q, want %q", endpoint, want)
+	}
+}
+
+func TestNtfySend(t *testing.T) {
+	server, record := pushStandIn(t, http.StatusOK, `{"id":"abc"}`)
+	notifier := newTestNotifier(t, `{"name":"ntfy","type":"ntfy","url":"`+server.URL+`/bangumi","token":"tk_1","priority":"high","tags":"tv,warning"}`)
+
+	if err := notifier.Send(pushTestEvent()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if record.method != http.MethodPost || record.path != "/bangumi" {
+		t.Fatalf("request = %s %s, want POST /bangumi", record.method, record.path)
+	}
+	// 请求头只能是ASCII，标题使用RFC 2047编码
+	title := record.header.Get("Title")
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(title); err != nil || decoded != "❌ 番剧下载失败" || !strings.HasPrefix(title, "=?utf-8?q?") {
+		t.Errorf("Title = %q, want RFC 2047 encoded title", title)
+	}
+	if record.header.Get("Priority") != "high" || record.header.Get("Tags") != "tv,warning" || record.header.Get("Authorization") != "Bearer tk_1" {
+		t.Errorf("headers = %v", record.header)
+	}
+	if !strings.Contains(record.body, "下载超时") {
+		t.Errorf("body = %q", record.body)
+	}
+}
+
+func TestNtfySendHTTPError(t *testing.T) {
+	server, _ := pushStandIn(t, http.StatusForbidden, `{"code":40301,"error":"forbidden"}`)
+	notifier := newTestNotifier(t, `{"name":"ntfy","type":"ntfy","url":"`+server.URL+`/bangumi"}`)
+
+	err := notifier.Send(pushTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "状态码: 403") {
+		t.Fatalf("Send error = %v, want status 403", err)
+	}
+}
+
+func TestGotifySend(t *testing.T) {
+	server, record := pushStandIn(t, http.StatusOK, `{"id":1}`)
+	notifier := newTestNotifier(t, `{"name":"gotify","type":"gotify","url":"`+server.URL+`/","token":"A&b","priority":0,"icon_url":"https://example.com/poster.jpg"}`)
+
+	if err := notifier.Send(pushTestEvent()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if record.method != http.MethodPost || record.path != "/message" || record.query.Get("token") != "A&b" {
+		t.Fatalf("request = %s %s?%s, want POST /message with token", record.method, record.path, record.query.Encode())
+	}
+	payload := decodeJSONBody(t, record.body)
+	if payload["title"] != "❌ 番剧下载失败" || payload["priority"] != float64(0) {
+		t.Errorf("payload = %v", payload)
+	}
+	extras, _ := payload["extras"].(map[string]interface{})
+	notification, _ := extras["client::notification"].(map[string]interface{})
+	if notification["bigImageUrl"] != "https://example.com/poster.jpg" {
+		t.Errorf("extras = %v", payload["extras"])
+	}
+}
+
+func TestGotifySendHTTPError(t *testing.T) {
+	server, _ := pushStandIn(t, http.StatusUnauthorized, `{"error":"Unauthorized","errorCode":401}`)
+	notifier := newTestNotifier(t, `{"name":"gotify","type":"gotify","url":"`+server.URL+`","token":"bad"}`)
+
+	err := notifier.Send(pushTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "状态码: 401") {
+		t.Fatalf("Send error = %v, want status 401", err)
+	}
+}
+
+func TestPushPlusSend(t *testing.T) {
+	server, record := pushStandIn(t, http.StatusOK, `{"code":200,"msg":"请求成功","data":"1"}`)
+	notifier := newTestNotifier(t, `{"name":"pushplus","type":"pushplus","url":"`+server.URL+`","token":"pp","topic":"anime"}`)
+
+	if err := notifier.Send(pushTestEvent()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if record.method != http.MethodPost || record.path != "/send" {
+		t.Fatalf("request = %s %s, want POST /send", record.method, record.path)
+	}
+	payload := decodeJSONBody(t, record.body)
+	want := map[string]string{"token": "pp", "title": "❌ 番剧下载失败", "template": "txt", "topic": "anime"}
+	for key, value := range want {
+		if payload[key] != value {
+			t.Errorf("%s = %v, want %q", key, payload[key], value)
+		}
+	}
+}
+
+func TestPushPlusSendErrorCode(t *testing.T) {
+	// PushPlus 出错时HTTP状态码仍为200，错误码在响应中
+	server, _ := pushStandIn(t, http.StatusOK, `{"code":903,"msg":"无效的用户令牌"}`)
+	notifier := newTestNotifier(t, `{"name":"pushplus","type":"pushplus","url":"`+server.URL+`","token":"bad"}`)
+
+	err := notifier.Send(pushTestEvent())
+	if err == nil || !strings.Contains(err.Error(), "code: 903") {
+		t.Fatalf("Send error = %v, want code 903", err)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QBittorrentDownloader 通过 qBittorrent WebUI API (v2) 下载，任务ID为种子的infohash
+type QBittorrentDownloader struct {
+	config   DownloaderConfig
+	baseURL  string
+	client   *http.Client
+	loggedIn bool
+	mutex    sync.Mutex
+}
+
+// qbTorrent torrents/info 接口返回的种子信息
+type qbTorrent struct {
+	Hash         string  `json:"hash"`
+	Name         string  `json:"name"`
+	State        string  `json:"state"`
+	Progress     float64 `json:"progress"`
+	TotalSize    int64   `json:"total_size"`
+	SavePath     string  `json:"save_path"`
+	ContentPath  string  `json:"content_path"`
+	AddedOn      int64   `json:"added_on"`
+	CompletionOn int64   `json:"completion_on"`
+}
+
+// NewQBittorrentDownloader 创建qBittorrent下载器
+func NewQBittorrentDownloader(config DownloaderConfig) *QBittorrentDownloader {
+	jar, _ := cookiejar.New(nil)
+	return &QBittorrentDownloader{
+		config:  config,
+		baseURL: strings.TrimSuffix(config.URL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second, Jar: jar},
+	}
+}
+
+// Name 下载器名称
+func (qb *QBittorrentDownloader) Name() string {
+	return qb.config.Name
+}
+
+// login 登录WebUI，会话保存在cookie中
+func (qb *QBittorrentDownloader) login() error {
+	form := url.Values{"username": {qb.config.Username}, "password": {qb.config.Password}}
+	req, err := http.NewRequest("POST", qb.baseURL+"/api/v2/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// 开启CSRF保护时需要Referer与WebUI地址一致
+	req.Header.Set("Referer", qb.baseURL)
+
+	resp, err := qb.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("qBittorrent登录失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return fmt.Errorf("qBittorrent登录失败: HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	qb.loggedIn = true
+	return nil
+}
+
+// call 调用API，会话过期（403）时重新登录一次
+func (qb *QBittorrentDownloader) call(method, path string, newBody func() (io.Reader, string, error)) ([]byte, error) {
+	qb.mutex.Lock()
+	defer qb.mutex.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if !qb.loggedIn {
+			if err := qb.login(); err != nil {
+				return nil, err
+			}
+		}
+
+		var body io.Reader
+		contentType := ""
+		if newBody != nil {
+			var err error
+			if body, contentType, err = newBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := http.NewRequest(method, qb.baseURL+path, body)
+		if err != nil {
+			return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("Referer", qb.baseURL)
+
+		resp, err := qb.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("请求qBittorrent失败: %v", err)
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("读取响应失败: %v", err)
+		}
+
+		if resp.StatusCode == http.StatusForbidden {
+			qb.loggedIn = false
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("qBittorrent返回错误: HTTP %d %s", resp.StatusCode, strings.TrimSpace(string(data)))
+		}
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("qBittorrent认证失败")
+}
+
+// postForm 以表单方式调用API
+func (qb *QBittorrentDownloader) postForm(path string, form url.Values) ([]byte, error) {
+	return qb.call("POST", path, func() (io.Reader, string, error) {
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	})
+}
+
+// ResolveFolder 本地下载器直接使用目录路径，未指定时使用配置的保存目录
+func (qb *QBittorrentDownloader) ResolveFolder(folderPath string) (string, error) {
+	if folderPath == "" {
+		return qb.config.SavePath, nil
+	}
+	return folderPath, nil
+}
+
+// AddTask 添加磁力链接或种子链接，name 作为任务名称
+// 种子地址中没有infohash时，下载种子文件计算infohash，并直接上传种子文件
+func (qb *QBittorrentDownloader) AddTask(name, link, folder string) (string, error) {
+	var torrent []byte
+	hash, err := linkInfoHash(link)
+	if err != nil {
+		if strings.HasPrefix(link, "magnet:") {
+			return "", err
+		}
+		data, meta, fetchErr := downloadTorrentFile(context.Background(), link)
+		if fetchErr != nil {
+			return "", fmt.Errorf("%v，下载种子文件失败: %v", err, fetchErr)
+		}
+		hash, torrent = meta.InfoHash, data
+	}
+
+	log.Printf("📥 添加qBittorrent任务: %s", name)
+
+	// torrents/add 只接受 multipart/form-data
+	data, err := qb.call("POST", "/api/v2/torrents/add", func() (io.Reader, string, error) {
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		if torrent != nil {
+			part, err := w.CreateFormFile("torrents", hash+".torrent")
+			if err != nil {
+				return nil, "", err
+			}
+			part.Write(torrent)
+		} else {
+			w.WriteField("urls", link)
+		}
+		if name != "" {
+			w.WriteField("rename", name)
+		}
+		if folder != "" {
+			w.WriteField("savepath", folder)
+		}
+		if qb.config.Category != "" {
+			w.WriteField("category", qb.config.Category)
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return &buf, w.FormDataContentType(), nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("添加qBittorrent任务失败: %v", err)
+	}
+
+	// 添加失败时仍返回 HTTP 200，响应为 Fails.；任务已存在时也会这样返回
+	if strings.TrimSpace(string(data)) == "Fails." {
+		if existing, err := qb.GetTask(hash); err == nil && existing != nil {
+			log.Printf("🔁 qBittorrent任务已存在: %s", hash)
+			return hash, nil
+		}
+		return "", fmt.Errorf("添加qBittorrent任务失败: qBittorrent无法添加该链接")
+	}
+
+	log.Printf("✅ qBittorrent任务添加成功: %s", hash)
+	return hash, nil
+}
+
+// GetTask 查询单个任务
+func (qb *QBittorrentDownloader) GetTask(taskID string) (*DownloadTask, error) {
+	tasks, err := qb.listTasks(taskID)
+	if err != nil {
+		return nil, err
+	}
+	return findTaskIn(tasks, taskID)
+}
+
+// ListTasks 列出所有任务
+func (qb *QBittorrentDownloader) ListTasks() ([]*DownloadTask, error) {
+	return qb.listTasks("")
+}
+
+// listTasks 查询任务，hashes 为空时返回全部
+func (qb *QBittorrentDownloader) listTasks(hashes string) ([]*DownloadTask, error) {
+	path := "/api/v2/torrents/info"
+	if hashes != "" {
+		path += "?hashes=" + url.QueryEscape(hashes)
+	}
+
+	data, err := qb.call("GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("获取qBittorrent任务列表失败: %v", err)
+	}
+
+	var torrents []qbTorrent
+	if err := json.Unmarshal(data, &torrents); err != nil {
+		return nil, fmt.Errorf("解析qBittorrent任务列表失败: %v", err)
+	}
+
+	tasks := make([]*DownloadTask, 0, len(torrents))
+	for _, t := range torrents {
+		task := &DownloadTask{
+			ID:        strings.ToLower(t.Hash),
+			Name:      t.Name,
+			Phase:     qbPhase(t.State),
+			Progress:  int(t.Progress * 100),
+			FileID:    t.ContentPath,
+			FileSize:  t.TotalSize,
+			Message:   t.State,
+			CreatedAt: time.Unix(t.AddedOn, 0),
+		}
+		if t.CompletionOn > 0 {
+			task.UpdatedAt = time.Unix(t.CompletionOn, 0)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// qbPhase 将qBittorrent的种子状态转换为统一状态
+func qbPhase(state string) string {
+	switch state {
+	case "error", "missingFiles":
+		return TaskPhaseError
+	case "uploading", "stalledUP", "pausedUP", "stoppedUP", "queuedUP", "checkingUP", "forcedUP":
+		return TaskPhaseComplete
+	case "queuedDL":
+		return TaskPhasePending
+	}
+	return TaskPhaseRunning
+}
+
+// RemoveTask 删除任务
+func (qb *QBittorrentDownloader) RemoveTask(taskID string, deleteFiles bool) error {
+	log.Printf("🗑️  删除qBittorrent任务: %s (删除文件: %v)", taskID, deleteFiles)
+
+	form := url.Values{"hashes": {taskID}, "deleteFiles": {fmt.Sprint(deleteFiles)}}
+	if _, err := qb.postForm("/api/v2/torrents/delete", form); err != nil {
+		return fmt.Errorf("删除qBittorrent任务失败: %v", err)
+	}
+	return nil
+}
+
+// RetryTask 重新校验并继续下载
+func (qb *QBittorrentDownloader) RetryTask(taskID string) error {
+	log.Printf("🔄 重试qBittorrent任务: %s", taskID)
+
+	form := url.Values{"hashes": {taskID}}
+	if _, err := qb.postForm("/api/v2/torrents/recheck", form); err != nil {
+		return fmt.Errorf("重试qBittorrent任务失败: %v", err)
+	}
+
+	// qBittorrent 5.0 起 resume 改名为 start
+	if _, err := qb.postForm("/api/v2/torrents/start", form); err != nil {
+		if _, err := qb.postForm("/api/v2/torrents/resume", form); err != nil {
+			return fmt.Errorf("重试qBittorrent任务失败: %v", err)
+		}
+	}
+	return nil
+}
@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 )
 
 // QQBot 表示与 QQ 机器人 API 交互的客户端
@@ -22,44 +25,91 @@ func NewQQBot(url, token string) *QQBot {
 	}
 }
 
-// SendPrivateMessage 向特定用户发送私信
-func (bot *QQBot) SendPrivateMessage(userID, message string) (string, error) {
-	// 使用结构体和 json.Marshal 来确保生成有效的 JSON
-	type TextData struct {
-		Text string `json:"text"`
-	}
+// QQMessageSegment OneBot v11 消息段
+type QQMessageSegment struct {
+	Type string            `json:"type"`
+	Data map[string]string `json:"data"`
+}
+
+// QQText 文本消息段
+func QQText(text string) QQMessageSegment {
+	return QQMessageSegment{Type: "text", Data: map[string]string{"text": text}}
+}
+
+// QQAt @某人的消息段，userID 为 all 时@全体成员
+func QQAt(userID string) QQMessageSegment {
+	return QQMessageSegment{Type: "at", Data: map[string]string{"qq": userID}}
+}
 
-	type MessageItem struct {
-		Type string   `json:"type"`
-		Data TextData `json:"data"`
+// QQImage 图片消息段，file 可以是网络图片地址
+func QQImage(file string) QQMessageSegment {
+	return QQMessageSegment{Type: "image", Data: map[string]string{"file": file}}
+}
+
+// QQResponse OneBot API 的返回结果，retcode 不为0表示失败（async 表示已加入队列，也算成功）
+type QQResponse struct {
+	Status  string `json:"status"` // ok / async / failed
+	RetCode int    `json:"retcode"`
+	Message string `json:"message"` // NapCat等实现的错误说明
+	Wording string `json:"wording"`
+	Echo    string `json:"echo"`
+	Data    struct {
+		MessageID int64 `json:"message_id"`
+	} `json:"data"`
+}
+
+// err 将失败的返回结果转换为错误
+func (r *QQResponse) err() error {
+	if r.RetCode == 0 || r.Status == "async" {
+		return nil
 	}
 
-	type RequestPayload struct {
-		UserID  string        `json:"user_id"`
-		Message []MessageItem `json:"message"`
+	reason := r.Wording
+	if reason == "" {
+		reason = r.Message
+	}
+	if reason == "" {
+		reason = r.Status
 	}
+	return fmt.Errorf("QQ机器人返回错误 (retcode: %d): %s", r.RetCode, reason)
+}
+
+// SendPrivateMessage 向特定用户发送私信
+func (bot *QQBot) SendPrivateMessage(userID string, message []QQMessageSegment) (*QQResponse, error) {
+	return bot.call("send_private_msg", map[string]interface{}{
+		"user_id": userID,
+		"message": message,
+	})
+}
+
+// SendGroupMessage 向群发送消息
+func (bot *QQBot) SendGroupMessage(groupID string, message []QQMessageSegment) (*QQResponse, error) {
+	return bot.call("send_group_msg", map[string]interface{}{
+		"group_id": groupID,
+		"message":  message,
+	})
+}
 
-	payload := RequestPayload{
-		UserID: userID,
-		Message: []MessageItem{
-			{
-				Type: "text",
-				Data: TextData{
-					Text: message,
-				},
-			},
-		},
+// endpoint API地址，url 可以是OneBot HTTP API的根地址，也可以是某个发送接口的地址（如 /send_private_msg）
+func (bot *QQBot) endpoint(action string) string {
+	base := strings.TrimRight(bot.URL, "/")
+	if i := strings.LastIndex(base, "/"); i >= 0 && strings.HasPrefix(base[i+1:], "send_") {
+		base = base[:i]
 	}
+	return base + "/" + action
+}
 
-	jsonData, err := json.Marshal(payload)
+// call 调用OneBot API并解析返回结果
+func (bot *QQBot) call(action string, params interface{}) (*QQResponse, error) {
+	jsonData, err := json.Marshal(params)
 	if err != nil {
-		return "", fmt.Errorf("JSON编码请求失败: %w", err)
+		return nil, fmt.Errorf("JSON编码请求失败: %w", err)
 	}
 
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", bot.URL, bytes.NewBuffer(jsonData))
+	client := &http.Client{Timeout: 30 * time.Second}
+	req, err := http.NewRequest("POST", bot.endpoint(action), bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建HTTP请求失败: %w", err)
+		return nil, fmt.Errorf("创建HTTP请求失败: %w", err)
 	}
 
 	req.Header.Add("Content-Type", "application/json")
@@ -67,14 +117,167 @@ func (bot *QQBot) SendPrivateMessage(userID, message string) (string, error) {
 
 	res, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送HTTP请求失败: %w", err)
+		return nil, fmt.Errorf("发送HTTP请求失败: %w", err)
 	}
 	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取响应失败: %w", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	var response QQResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("解析响应失败 (HTTP %d): %s", res.StatusCode, body)
+	}
+	if err := response.err(); err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("QQ机器人请求失败，状态码: %d", res.StatusCode)
+	}
+
+	return &response, nil
+}
+
+// QQNotifier 通过QQ机器人向多个用户和群发送通知，配置了 listen 时接收这些用户发送的命令
+type QQNotifier struct {
+	name         string
+	bot          *QQBot
+	users        []string
+	groups       []string
+	mentions     []string // 群消息中@的用户
+	images       bool
+	allowedUsers map[string]bool
+	renderer     *MessageRenderer
+	listen       string
+	secret       string
+}
+
+// QQOptions QQ渠道的配置
+type QQOptions struct {
+	URL    string   `json:"url"` // OneBot HTTP API 的根地址或发送接口地址
+	Token  string   `json:"token"`
+	Users  []string `json:"users"`  // 私聊通知的用户，也是允许发送命令的用户
+	Groups []string `json:"groups"` // 群聊通知的群
+	// Mentions 群消息中@的用户，all 表示@全体成员
+	Mentions []string `json:"mentions"`
+	// Images 是否在消息中附带RSS项目封面或Bangumi番剧海报
+	Images bool `json:"images"`
+	// Listen 接收OneBot v11事件（HTTP上报或反向WebSocket）的监听地址，如 :8081，为空时不接收QQ命令
+	Listen string `json:"listen"`
+	// Secret OneBot HTTP上报的签名密钥
+	Secret string `json:"secret"`
+}
+
+// newQQNotifier 根据通知渠道配置创建QQ通知
+func newQQNotifier(config NotifierConfig) (Notifier, error) {
+	var options QQOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.URL == "" {
+		return nil, fmt.Errorf("缺少url")
+	}
+	if len(options.Users) == 0 && len(options.Groups) == 0 {
+		return nil, fmt.Errorf("缺少users或groups")
+	}
+
+	renderer, err := NewMessageRenderer(formatText, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	allowedUsers := make(map[string]bool, len(options.Users))
+	for _, userID := range options.Users {
+		allowedUsers[userID] = true
+	}
+
+	return &QQNotifier{
+		name:         config.Name,
+		bot:          NewQQBot(options.URL, options.Token),
+		users:        options.Users,
+		groups:       options.Groups,
+		mentions:     options.Mentions,
+		images:       options.Images,
+		allowedUsers: allowedUsers,
+		renderer:     renderer,
+		listen:       options.Listen,
+		secret:       options.Secret,
+	}, nil
+}
+
+// Name 渠道名称
+func (qn *QQNotifier) Name() string {
+	return qn.name
+}
+
+// WantsImages 是否在消息中附带图片
+func (qn *QQNotifier) WantsImages() bool {
+	return qn.images
+}
+
+// QQ通知目标的前缀，用于只重试发送失败的用户和群
+const (
+	qqUserTarget  = "user:"
+	qqGroupTarget = "group:"
+)
+
+// Send 向所有用户和群发送通知，部分目标失败时返回 *TargetError
+func (qn *QQNotifier) Send(event *Event) error {
+	return qn.send(event, qn.users, qn.groups)
+}
+
+// SendTo 只向 targets 中仍在配置里的用户（user:QQ号）和群（group:群号）发送
+func (qn *QQNotifier) SendTo(event *Event, targets []string) error {
+	var users, groups []string
+	for _, target := range targets {
+		if userID, ok := strings.CutPrefix(target, qqUserTarget); ok {
+			users = append(users, userID)
+		} else if groupID, ok := strings.CutPrefix(target, qqGroupTarget); ok {
+			groups = append(groups, groupID)
+		}
+	}
+	return qn.send(event, filterTargets(qn.users, users), filterTargets(qn.groups, groups))
+}
+
+// send 向 users 和 groups 发送通知
+func (qn *QQNotifier) send(event *Event, users, groups []string) error {
+	message := []QQMessageSegment{QQText(qn.renderer.Render(event))}
+	if qn.images && event.ImageURL != "" {
+		message = append(message, QQImage(event.ImageURL))
 	}
 
-	return string(body), nil
+	var failed []string
+	for _, userID := range users {
+		response, err := qn.bot.SendPrivateMessage(userID, message)
+		if err != nil {
+			log.Printf("❌ 发送QQ通知失败 (用户: %s): %v", userID, err)
+			failed = append(failed, qqUserTarget+userID)
+			continue
+		}
+		log.Printf("📱 QQ通知已发送 (用户: %s, 消息ID: %d)", userID, response.Data.MessageID)
+	}
+
+	// 群消息在开头@指定的用户
+	groupMessage := make([]QQMessageSegment, 0, len(qn.mentions)*2+len(message))
+	for _, userID := range qn.mentions {
+		groupMessage = append(groupMessage, QQAt(userID), QQText(" "))
+	}
+	groupMessage = append(groupMessage, message...)
+
+	for _, groupID := range groups {
+		response, err := qn.bot.SendGroupMessage(groupID, groupMessage)
+		if err != nil {
+			log.Printf("❌ 发送QQ群通知失败 (群: %s): %v", groupID, err)
+			failed = append(failed, qqGroupTarget+groupID)
+			continue
+		}
+		log.Printf("📱 QQ群通知已发送 (群: %s, 消息ID: %d)", groupID, response.Data.MessageID)
+	}
+
+	if len(failed) > 0 {
+		return &TargetError{Failed: failed, Total: len(users) + len(groups)}
+	}
+	return nil
 }
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Release 从发布标题中解析出的结构化信息
+type Release struct {
+	Title      string   // 原始标题
+	Group      string   // 字幕组/压制组
+	Series     string   // 番剧名（第一个名称，已去除季度标记）
+	Aliases    []string // 番剧的其他名称（通常是罗马音或英文名）
+	Season     int      // 季度，未标明时为0
+	Episode    int      // 集数，未识别时为0
+	EpisodeEnd int      // 合集的最后一集，单集时为0
+	Batch      bool     // 是否为合集
+	Version    int      // 修订版本，v2/v3等，默认为1
+	Resolution string   // 分辨率，统一为 1080p/2160p 等形式
+	Codec      string   // 视频编码：HEVC/AVC/AV1
+	Source     string   // 片源：WebRip/WEB-DL/BDRip/TV/DVDRip
+	Languages  []string // 字幕语言：CHS/CHT/JPN/ENG
+}
+
+// releaseToken 标题中的一个片段，可能是括号内容或括号外的文本
+type releaseToken struct {
+	text      string
+	bracketed bool
+}
+
+var (
+	// 通用的番剧标记，如 ★10月新番★
+	releaseStarRegex = regexp.MustCompile(`★[^★\[]*★?`)
+
+	// 分辨率
+	releaseResolutionRegex = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])(\d{3,4})[pi](?:$|[^0-9a-z])`)
+	releaseDimensionRegex  = regexp.MustCompile(`(?i)\d{3,4}\s*[x×]\s*(\d{3,4})`)
+	release4KRegex         = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])4k(?:$|[^0-9a-z])`)
+
+	// 视频编码
+	releaseHEVCRegex = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])(?:hevc|x265|h\.?265)`)
+	releaseAVCRegex  = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])(?:avc|x264|h\.?264)`)
+	releaseAV1Regex  = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])av1(?:$|[^0-9a-z])`)
+
+	// 片源
+	releaseWebRipRegex = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])web-?rip(?:$|[^0-9a-z])`)
+	releaseWebDLRegex  = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])web(?:-?dl)?(?:$|[^0-9a-z])`)
+	releaseBDRegex     = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])(?:bd-?rip|bd|blu-?ray|bdmv)(?:$|[^0-9a-z])`)
+	releaseDVDRegex    = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])dvd(?:-?rip)?(?:$|[^0-9a-z])`)
+	releaseTVRegex     = regexp.MustCompile(`(?i)(?:^|[^0-9a-z])(?:tv-?rip|hdtv)(?:$|[^0-9a-z])`)
+
+	// 修订版本，如 05v2、[v2]
+	releaseVersionRegex = regexp.MustCompile(`(?i)(?:^|[\s\[\]\d])v([2-9])(?:$|[\s\[\]])`)
+
+	// 括号内单独的集数标记，如 12、05v2、第12集、01-28 修正合集、01-23TV全集+SP
+	releaseEpisodeTokenRegex = regexp.MustCompile(`(?i)^(?:第|EP?)?\s*(\d{1,4})(?:\s*[-~]\s*(\d{1,4}))?\s*(?:v(\d))?\s*(?:[话話集])?\s*(?:TV|BD)?\s*(END|Fin|完|完结|完結|(?:精校|修正)?合集|全集|Batch|Complete)?(?:\s*[+＋]\s*(?:SP|OVA|OAD|特典|剧场版|劇場版)s?)*$`)
+
+	// 文本中的集数标记
+	releaseSxxExxRegex    = regexp.MustCompile(`(?i)\bS(\d{1,2})E(\d{1,4})(?:-E?(\d{1,4}))?(?:v(\d))?\b`)
+	releaseDashEpRegex    = regexp.MustCompile(`(?i)\s[-–]\s(\d{1,4})(?:\s*[-~]\s*(\d{1,4}))?(?:v(\d))?(?:\s*(?:END|Fin))?(?:\s|$)`)
+	releaseChineseEpRegex = regexp.MustCompile(`第\s*([0-9一二三四五六七八九十百]+)\s*[话話集]`)
+	releaseEPRegex        = regexp.MustCompile(`(?i)\bEP?(\d{1,4})(?:v(\d))?\b`)
+
+	// 季度标记
+	releaseSeasonRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\s*\bSeason\s*(\d{1,2})\b`),
+		regexp.MustCompile(`(?i)\s*\b(\d{1,2})(?:st|nd|rd|th)\s+Season\b`),
+		regexp.MustCompile(`(?i)\s+S(\d{1,2})$`),
+		regexp.MustCompile(`\s*第\s*([0-9一二三四五六七八九十]+)\s*季`),
+	}
+	releaseSeasonTokenRegex = regexp.MustCompile(`(?i)^(?:第\s*([0-9一二三四五六七八九十]+)\s*季|Season\s*(\d{1,2})|S(\d{1,2})|(\d{1,2})(?:st|nd|rd|th)\s+Season)$`)
+
+	// 字幕语言标记，整个括号内容都由这些片段组成时才视为语言标记
+	releaseLanguageTokenRegex = regexp.MustCompile(`(?i)^(?:简|簡|繁|日|中|英|体|體|文|双语|雙語|多语|多語|内封|內封|内嵌|內嵌|外挂|外掛|字幕|CHS|CHT|GB|BIG5|JPN|JPSC|JPTC|JP|ENG|SC|TC|[\s&_\-/+、])+$`)
+	releaseLanguageWordRegex  = regexp.MustCompile(`(?i)^(?:CHS|CHT|GB|BIG5|JPN|JPSC|JPTC|ENG|SC|TC)$`)
+
+	// 其他常见的元数据标记
+	releaseMetaWordRegex = regexp.MustCompile(`(?i)^(?:\d{3,4}[pi]|\d{3,4}[x×]\d{3,4}|4k|hevc|avc|x26[45]|h\.?26[45]|av1|\d{1,2}bit|ma10p|hi10p|aac|flac|opus|ac3|eac3|dts|ddp?\d?(?:\.\d)?|ass(?:x\d)?|srt|pgs|mp4|mkv|web|webrip|web-?dl|webdl|dl|rip|bd|bdrip|blu-?ray|dvd|dvdrip|tv|tvrip|hdtv|baha|cr|b-global|bilibili|abema|netflix|nf|amzn|viutv|multiple|multi|subtitles?|multi-subs|[0-9a-f]{8}|v\d)$`)
+	releaseMetaTextRegex = regexp.MustCompile(`招募|新番|月番|合集|全集|修正|仅限港澳台|僅限港澳台|无字幕|無字幕|生肉|粤语|粵語|国语|國語`)
+	releaseYearRegex     = regexp.MustCompile(`^(?:19|20)\d{2}$`)
+	// 单独的分类标记，如 [国漫]、[剧场版]
+	releaseCategoryRegex = regexp.MustCompile(`^(?:国漫|國漫|国创|國創|日漫|美漫|动画|動畫|动漫|動漫|番剧|番劇|剧场版|劇場版|电影|電影|OVA|OAD)$`)
+	// 名称首尾与名称之间隔着空白的分隔符，如 "Title -"，紧贴名称的保留，如 "86 -Eighty Six-"
+	releaseDanglingRegex = regexp.MustCompile(`^(?:[-–_|]+\s+)+|(?:\s+[-–_|]+)+$`)
+	releaseWordSplit     = regexp.MustCompile(`[\s\-_+&/,]+`)
+)
+
+// ParseRelease 解析番剧发布标题
+func ParseRelease(title string) *Release {
+	r := &Release{
+		Title:   title,
+		Version: 1,
+	}
+
+	normalized := normalizeReleaseTitle(title)
+	tokens := tokenizeRelease(normalized)
+
+	r.Resolution = parseReleaseResolution(normalized)
+	r.Codec = parseReleaseCodec(normalized)
+	r.Source = parseReleaseSource(normalized)
+	if m := releaseVersionRegex.FindStringSubmatch(normalized); m != nil {
+		r.Version, _ = strconv.Atoi(m[1])
+	}
+
+	start := 0
+	if len(tokens) > 1 && tokens[0].bracketed && !isReleaseMeta(tokens[0].text) && !releaseEpisodeTokenRegex.MatchString(tokens[0].text) {
+		r.Group = strings.TrimSpace(tokens[0].text)
+		start = 1
+	}
+
+	var names []string
+	seriesDone := false
+	for _, token := range tokens[start:] {
+		text := strings.TrimSpace(token.text)
+		if text == "" {
+			continue
+		}
+
+		if token.bracketed {
+			switch {
+			case releaseYearRegex.MatchString(text):
+				// 年份，例如 (2023)
+			case r.Episode == 0 && r.parseEpisodeToken(text):
+				seriesDone = true
+			case releaseSeasonTokenRegex.MatchString(text):
+				r.parseSeasonToken(text)
+			case isReleaseMeta(text):
+				r.addLanguages(text)
+			case !seriesDone:
+				names = append(names, text)
+			}
+			continue
+		}
+
+		if r.Episode == 0 && !seriesDone {
+			before, found := r.parseEpisodeText(text)
+			if before = trimReleaseName(before); before != "" {
+				names = append(names, before)
+			}
+			if found {
+				seriesDone = true
+			}
+			continue
+		}
+
+		// 集数之后的文本，通常是 GB MP4_1080P 之类的元数据
+		for _, word := range releaseWordSplit.Split(text, -1) {
+			if releaseLanguageWordRegex.MatchString(word) {
+				r.addLanguages(word)
+			}
+		}
+	}
+
+	r.setSeries(names)
+	return r
+}
+
+// normalizeReleaseTitle 统一括号形式并去除扩展名与通用标记
+func normalizeReleaseTitle(title string) string {
+	replacer := strings.NewReplacer("【", "[", "】", "]", "（", "(", "）", ")", "［", "[", "］", "]")
+	normalized := replacer.Replace(title)
+
+	switch strings.ToLower(path.Ext(normalized)) {
+	case ".mkv", ".mp4", ".avi", ".ts":
+		normalized = strings.TrimSuffix(normalized, path.Ext(normalized))
+	}
+
+	normalized = releaseStarRegex.ReplaceAllString(normalized, " ")
+	return strings.TrimSpace(normalized)
+}
+
+// tokenizeRelease 按方括号和圆括号切分标题
+func tokenizeRelease(title string) []releaseToken {
+	var tokens []releaseToken
+	var current strings.Builder
+	closer := rune(0)
+
+	flush := func(bracketed bool) {
+		if current.Len() > 0 || bracketed {
+			tokens = append(tokens, releaseToken{text: current.String(), bracketed: bracketed})
+		}
+		current.Reset()
+	}
+
+	for _, c := range title {
+		switch {
+		case closer == 0 && (c == '[' || c == '('):
+			flush(false)
+			if c == '[' {
+				closer = ']'
+			} else {
+				closer = ')'
+			}
+		case closer != 0 && c == closer:
+			flush(true)
+			closer = 0
+		default:
+			current.WriteRune(c)
+		}
+	}
+	flush(false)
+
+	// 去掉只有空白的文本片段
+	result := tokens[:0]
+	for _, token := range tokens {
+		if token.bracketed || strings.TrimSpace(token.text) != "" {
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
+// isReleaseMeta 判断括号内容是否为分辨率、编码、语言等元数据
+func isReleaseMeta(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return true
+	}
+	if releaseLanguageTokenRegex.MatchString(text) || releaseMetaTextRegex.MatchString(text) || releaseCategoryRegex.MatchString(text) {
+		return true
+	}
+
+	for _, word := range releaseWordSplit.Split(text, -1) {
+		if word == "" {
+			continue
+		}
+		if !releaseMetaWordRegex.MatchString(word) && !releaseLanguageWordRegex.MatchString(word) && !releaseLanguageTokenRegex.MatchString(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseEpisodeToken 解析括号中的集数标记
+func (r *Release) parseEpisodeToken(text string) bool {
+	m := releaseEpisodeTokenRegex.FindStringSubmatch(text)
+	if m == nil {
+		return false
+	}
+
+	r.Episode, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		r.EpisodeEnd, _ = strconv.Atoi(m[2])
+		r.Batch = true
+	}
+	if m[3] != "" {
+		r.Version, _ = strconv.Atoi(m[3])
+	}
+	switch strings.ToLower(m[4]) {
+	case "精校合集", "修正合集", "合集", "全集", "batch", "complete":
+		r.Batch = true
+	}
+	return true
+}
+
+// parseEpisodeText 在括号外的文本中查找集数，返回集数之前的部分
+func (r *Release) parseEpisodeText(text string) (string, bool) {
+	if m := releaseSxxExxRegex.FindStringSubmatchIndex(text); m != nil {
+		r.Season, _ = strconv.Atoi(text[m[2]:m[3]])
+		r.Episode, _ = strconv.Atoi(text[m[4]:m[5]])
+		if m[6] >= 0 {
+			r.EpisodeEnd, _ = strconv.Atoi(text[m[6]:m[7]])
+			r.Batch = true
+		}
+		if m[8] >= 0 {
+			r.Version, _ = strconv.Atoi(text[m[8]:m[9]])
+		}
+		return text[:m[0]], true
+	}
+
+	// " - 12" 取最后一个，避免番剧名本身包含 " - "
+	if all := releaseDashEpRegex.FindAllStringSubmatchIndex(text, -1); len(all) > 0 {
+		m := all[len(all)-1]
+		r.Episode, _ = strconv.Atoi(text[m[2]:m[3]])
+		if m[4] >= 0 {
+			r.EpisodeEnd, _ = strconv.Atoi(text[m[4]:m[5]])
+			r.Batch = true
+		}
+		if m[6] >= 0 {
+			r.Version, _ = strconv.Atoi(text[m[6]:m[7]])
+		}
+		return text[:m[0]], true
+	}
+
+	if m := releaseChineseEpRegex.FindStringSubmatchIndex(text); m != nil {
+		r.Episode = parseChineseNumber(text[m[2]:m[3]])
+		return text[:m[0]], true
+	}
+
+	if m := releaseEPRegex.FindStringSubmatchIndex(text); m != nil && m[0] > 0 {
+		r.Episode, _ = strconv.Atoi(text[m[2]:m[3]])
+		if m[4] >= 0 {
+			r.Version, _ = strconv.Atoi(text[m[4]:m[5]])
+		}
+		return text[:m[0]], true
+	}
+
+	return text, false
+}
+
+// parseSeasonToken 解析单独的季度标记，如 [第二季]
+func (r *Release) parseSeasonToken(text string) {
+	m := releaseSeasonTokenRegex.FindStringSubmatch(text)
+	if m == nil || r.Season != 0 {
+		return
+	}
+	for _, group := range m[1:] {
+		if group != "" {
+			r.Season = parseChineseNumber(group)
+			return
+		}
+	}
+}
+
+// setSeries 从名称列表中确定番剧名、别名和季度
+func (r *Release) setSeries(names []string) {
+	var all []string
+	for _, name := range names {
+		for _, part := range strings.Split(name, "/") {
+			part = trimReleaseName(r.stripSeason(strings.TrimSpace(part)))
+			if part != "" {
+				all = append(all, part)
+			}
+		}
+	}
+
+	if len(all) == 0 {
+		return
+	}
+	r.Series = all[0]
+	r.Aliases = all[1:]
+}
+
+// trimReleaseName 去除名称首尾的空白和孤立的分隔符
+func trimReleaseName(name string) string {
+	name = releaseDanglingRegex.ReplaceAllString(strings.TrimSpace(name), "")
+	if strings.Trim(name, "-–_|") == "" {
+		return ""
+	}
+	return name
+}
+
+// stripSeason 去除名称中的季度标记并记录季度
+func (r *Release) stripSeason(name string) string {
+	for _, re := range releaseSeasonRegexes {
+		m := re.FindStringSubmatch(name)
+		if m == nil {
+			continue
+		}
+		if r.Season == 0 {
+			r.Season = parseChineseNumber(m[1])
+		}
+		name = re.ReplaceAllString(name, "")
+	}
+	return strings.TrimSpace(name)
+}
+
+// addLanguages 从语言标记中解析字幕语言
+func (r *Release) addLanguages(text string) {
+	upper := strings.ToUpper(text)
+	words := make(map[string]bool)
+	for _, word := range releaseWordSplit.Split(upper, -1) {
+		words[word] = true
+	}
+
+	isLanguageToken := releaseLanguageTokenRegex.MatchString(text)
+	hasChinese := func(chars ...string) bool {
+		if !isLanguageToken {
+			return false
+		}
+		for _, c := range chars {
+			if strings.Contains(text, c) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if hasChinese("简", "簡") || words["CHS"] || words["GB"] || words["SC"] || words["JPSC"] {
+		r.addLanguage("CHS")
+	}
+	if hasChinese("繁") || words["CHT"] || words["BIG5"] || words["TC"] || words["JPTC"] {
+		r.addLanguage("CHT")
+	}
+	if hasChinese("日") || words["JP"] || words["JPN"] || words["JPSC"] || words["JPTC"] {
+		r.addLanguage("JPN")
+	}
+	if hasChinese("英") || words["ENG"] {
+		r.addLanguage("ENG")
+	}
+}
+
+// addLanguage 添加一种字幕语言（去重）
+func (r *Release) addLanguage(lang string) {
+	for _, existing := range r.Languages {
+		if existing == lang {
+			return
+		}
+	}
+	r.Languages = append(r.Languages, lang)
+}
+
+// HasLanguage 判断是否包含指定字幕语言
+func (r *Release) HasLanguage(lang string) bool {
+	for _, existing := range r.Languages {
+		if strings.EqualFold(existing, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// EpisodeString 集数的显示形式，如 12、01-12
+func (r *Release) EpisodeString() string {
+	if r.Episode == 0 {
+		return ""
+	}
+	if r.EpisodeEnd > 0 {
+		return fmt.Sprintf("%02d-%02d", r.Episode, r.EpisodeEnd)
+	}
+	return fmt.Sprintf("%02d", r.Episode)
+}
+
+// Summary 用于日志的简要描述
+func (r *Release) Summary() string {
+	parts := []string{}
+	if r.Group != "" {
+		parts = append(parts, "字幕组="+r.Group)
+	}
+	if r.Series != "" {
+		parts = append(parts, "番剧="+r.Series)
+	}
+	if r.Season > 0 {
+		parts = append(parts, fmt.Sprintf("季=%d", r.Season))
+	}
+	if ep := r.EpisodeString(); ep != "" {
+		parts = append(parts, "集="+ep)
+	}
+	if r.Version > 1 {
+		parts = append(parts, fmt.Sprintf("v%d", r.Version))
+	}
+	if r.Resolution != "" {
+		parts = append(parts, r.Resolution)
+	}
+	if r.Codec != "" {
+		parts = append(parts, r.Codec)
+	}
+	if r.Source != "" {
+		parts = append(parts, r.Source)
+	}
+	if len(r.Languages) > 0 {
+		parts = append(parts, strings.Join(r.Languages, "+"))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// parseReleaseResolution 解析分辨率
+func parseReleaseResolution(title string) string {
+	if m := releaseResolutionRegex.FindStringSubmatch(title); m != nil {
+		return normalizeResolution(m[1])
+	}
+	if m := releaseDimensionRegex.FindStringSubmatch(title); m != nil {
+		return normalizeResolution(m[1])
+	}
+	if release4KRegex.MatchString(title) {
+		return "2160p"
+	}
+	return ""
+}
+
+// normalizeResolution 将高度统一为 1080p 的形式
+func normalizeResolution(height string) string {
+	if height == "1088" {
+		return "1080p"
+	}
+	return height + "p"
+}
+
+// parseReleaseCodec 解析视频编码
+func parseReleaseCodec(title string) string {
+	switch {
+	case releaseHEVCRegex.MatchString(title):
+		return "HEVC"
+	case releaseAV1Regex.MatchString(title):
+		return "AV1"
+	case releaseAVCRegex.MatchString(title):
+		return "AVC"
+	}
+	return ""
+}
+
+// parseReleaseSource 解析片源
+func parseReleaseSource(title string) string {
+	switch {
+	case releaseWebRipRegex.MatchString(title):
+		return "WebRip"
+	case releaseWebDLRegex.MatchString(title):
+		return "WEB-DL"
+	case releaseBDRegex.MatchString(title):
+		return "BDRip"
+	case releaseDVDRegex.MatchString(title):
+		return "DVDRip"
+	case releaseTVRegex.MatchString(title):
+		return "TV"
+	}
+	return ""
+}
+
+// parseChineseNumber 解析阿拉伯数字或一百以内的中文数字
+func parseChineseNumber(s string) int {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+
+	digits := map[rune]int{'一': 1, '二': 2, '三': 3, '四': 4, '五': 5, '六': 6, '七': 7, '八': 8, '九': 9}
+	total, current := 0, 0
+	for _, c := range s {
+		switch {
+		case c == '百':
+			if current == 0 {
+				current = 1
+			}
+			total += current * 100
+			current = 0
+		case c == '十':
+			if current == 0 {
+				current = 1
+			}
+			total += current * 10
+			current = 0
+		default:
+			current = digits[c]
+		}
+	}
+	return total + current
+}
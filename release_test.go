@@ -0,0 +1,168 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 来自 Mikan 和 Nyaa 的真实标题
+var releaseTests = []struct {
+	title string
+	want  Release
+}{
+	{
+		title: "[LoliHouse] 葬送的芙莉莲 / Sousou no Frieren - 12 [WebRip 1080p HEVC-10bit AAC][简繁内封字幕]",
+		want:  Release{Group: "LoliHouse", Series: "葬送的芙莉莲", Aliases: []string{"Sousou no Frieren"}, Episode: 12, Version: 1, Resolution: "1080p", Codec: "HEVC", Source: "WebRip", Languages: []string{"CHS", "CHT"}},
+	},
+	{
+		title: "[ANi] 葬送的芙莉蓮 - 12 [1080P][Baha][WEB-DL][AAC AVC][CHT][MP4]",
+		want:  Release{Group: "ANi", Series: "葬送的芙莉蓮", Episode: 12, Version: 1, Resolution: "1080p", Codec: "AVC", Source: "WEB-DL", Languages: []string{"CHT"}},
+	},
+	{
+		title: "[Lilith-Raws] 间谍过家家 / Spy x Family - 25 [Baha][WEB-DL][1080p][AVC AAC][CHT][MP4]",
+		want:  Release{Group: "Lilith-Raws", Series: "间谍过家家", Aliases: []string{"Spy x Family"}, Episode: 25, Version: 1, Resolution: "1080p", Codec: "AVC", Source: "WEB-DL", Languages: []string{"CHT"}},
+	},
+	{
+		title: "【喵萌奶茶屋】★10月新番★[葬送的芙莉莲 / Sousou no Frieren][12][1080p][简日双语][招募翻译]",
+		want:  Release{Group: "喵萌奶茶屋", Series: "葬送的芙莉莲", Aliases: []string{"Sousou no Frieren"}, Episode: 12, Version: 1, Resolution: "1080p", Languages: []string{"CHS", "JPN"}},
+	},
+	{
+		title: "[桜都字幕组] 鬼灭之刃 锻刀村篇 / Kimetsu no Yaiba Katanakaji no Sato Hen [05][1080p][简体内嵌]",
+		want:  Release{Group: "桜都字幕组", Series: "鬼灭之刃 锻刀村篇", Aliases: []string{"Kimetsu no Yaiba Katanakaji no Sato Hen"}, Episode: 5, Version: 1, Resolution: "1080p", Languages: []string{"CHS"}},
+	},
+	{
+		title: "[桜都字幕组] 鬼灭之刃 柱训练篇 / Kimetsu no Yaiba: Hashira Geiko-hen [01][1080p][简繁内封]",
+		want:  Release{Group: "桜都字幕组", Series: "鬼灭之刃 柱训练篇", Aliases: []string{"Kimetsu no Yaiba: Hashira Geiko-hen"}, Episode: 1, Version: 1, Resolution: "1080p", Languages: []string{"CHS", "CHT"}},
+	},
+	{
+		title: "[SweetSub][孤独摇滚！][Bocchi the Rock!][01-12 精校合集][WebRip][1080P][AVC 8bit][简日双语]",
+		want:  Release{Group: "SweetSub", Series: "孤独摇滚！", Aliases: []string{"Bocchi the Rock!"}, Episode: 1, EpisodeEnd: 12, Batch: true, Version: 1, Resolution: "1080p", Codec: "AVC", Source: "WebRip", Languages: []string{"CHS", "JPN"}},
+	},
+	{
+		title: "[喵萌Production&LoliHouse] 孤独摇滚！/ Bocchi the Rock! - 01v2 [WebRip 1080p HEVC-10bit AAC][简繁日内封字幕]",
+		want:  Release{Group: "喵萌Production&LoliHouse", Series: "孤独摇滚！", Aliases: []string{"Bocchi the Rock!"}, Episode: 1, Version: 2, Resolution: "1080p", Codec: "HEVC", Source: "WebRip", Languages: []string{"CHS", "CHT", "JPN"}},
+	},
+	{
+		title: "[Nekomoe kissaten][Kusuriya no Hitorigoto][05][1080p][JPSC]",
+		want:  Release{Group: "Nekomoe kissaten", Series: "Kusuriya no Hitorigoto", Episode: 5, Version: 1, Resolution: "1080p", Languages: []string{"CHS", "JPN"}},
+	},
+	{
+		title: "[北宇治字幕组] 药屋少女的呢喃 / Kusuriya no Hitorigoto [12v2][WebRip][1080p][HEVC_AAC][简日内嵌]",
+		want:  Release{Group: "北宇治字幕组", Series: "药屋少女的呢喃", Aliases: []string{"Kusuriya no Hitorigoto"}, Episode: 12, Version: 2, Resolution: "1080p", Codec: "HEVC", Source: "WebRip", Languages: []string{"CHS", "JPN"}},
+	},
+	{
+		title: "[Up to 21°C] 药屋少女的呢喃 / Kusuriya no Hitorigoto - 24 (Baha 1920x1080 AVC AAC MP4)",
+		want:  Release{Group: "Up to 21°C", Series: "药屋少女的呢喃", Aliases: []string{"Kusuriya no Hitorigoto"}, Episode: 24, Version: 1, Resolution: "1080p", Codec: "AVC"},
+	},
+	{
+		title: "[SubsPlease] Jujutsu Kaisen - 47 (1080p) [ABCD1234].mkv",
+		want:  Release{Group: "SubsPlease", Series: "Jujutsu Kaisen", Episode: 47, Version: 1, Resolution: "1080p"},
+	},
+	{
+		title: "[Erai-raws] Shingeki no Kyojin - The Final Season - 28 [1080p][Multiple Subtitle][ABCD1234]",
+		want:  Release{Group: "Erai-raws", Series: "Shingeki no Kyojin - The Final Season", Episode: 28, Version: 1, Resolution: "1080p"},
+	},
+	{
+		title: "[Erai-raws] Re Zero kara Hajimeru Isekai Seikatsu 3rd Season - 08 [1080p CR WEB-DL AVC AAC][MultiSub][ABCD1234]",
+		want:  Release{Group: "Erai-raws", Series: "Re Zero kara Hajimeru Isekai Seikatsu", Season: 3, Episode: 8, Version: 1, Resolution: "1080p", Codec: "AVC", Source: "WEB-DL"},
+	},
+	{
+		title: "[ANi] Re：從零開始的異世界生活 第三季 - 08 [1080P][Baha][WEB-DL][AAC AVC][CHT][MP4]",
+		want:  Release{Group: "ANi", Series: "Re：從零開始的異世界生活", Season: 3, Episode: 8, Version: 1, Resolution: "1080p", Codec: "AVC", Source: "WEB-DL", Languages: []string{"CHT"}},
+	},
+	{
+		title: "[Sakurato] Spy x Family Season 2 [03][AVC-8bit 1080p AAC][CHS]",
+		want:  Release{Group: "Sakurato", Series: "Spy x Family", Season: 2, Episode: 3, Version: 1, Resolution: "1080p", Codec: "AVC", Languages: []string{"CHS"}},
+	},
+	{
+		title: "[LoliHouse] 无职转生 第二季 / Mushoku Tensei S2 - 13 [WebRip 1080p HEVC-10bit AAC][简繁内封字幕]",
+		want:  Release{Group: "LoliHouse", Series: "无职转生", Aliases: []string{"Mushoku Tensei"}, Season: 2, Episode: 13, Version: 1, Resolution: "1080p", Codec: "HEVC", Source: "WebRip", Languages: []string{"CHS", "CHT"}},
+	},
+	{
+		title: "[Judas] Sousou no Frieren - S01E12 [1080p][HEVC x265 10bit][Multi-Subs] (Weekly)",
+		want:  Release{Group: "Judas", Series: "Sousou no Frieren", Season: 1, Episode: 12, Version: 1, Resolution: "1080p", Codec: "HEVC"},
+	},
+	{
+		title: "[NC-Raws] 间谍过家家 / Spy x Family - 12 (B-Global 3840x2160 HEVC AAC MKV)",
+		want:  Release{Group: "NC-Raws", Series: "间谍过家家", Aliases: []string{"Spy x Family"}, Episode: 12, Version: 1, Resolution: "2160p", Codec: "HEVC"},
+	},
+	{
+		title: "[织梦字幕组][尼尔：自动人形 Ver1.1a][NieR Automata Ver1.1a][第08集][1080P][AVC][简日双语]",
+		want:  Release{Group: "织梦字幕组", Series: "尼尔：自动人形 Ver1.1a", Aliases: []string{"NieR Automata Ver1.1a"}, Episode: 8, Version: 1, Resolution: "1080p", Codec: "AVC", Languages: []string{"CHS", "JPN"}},
+	},
+	{
+		title: "[爱恋字幕社][1月新番][迷宫饭][Dungeon Meshi][01][1080P][MP4][GB][简中]",
+		want:  Release{Group: "爱恋字幕社", Series: "迷宫饭", Aliases: []string{"Dungeon Meshi"}, Episode: 1, Version: 1, Resolution: "1080p", Languages: []string{"CHS"}},
+	},
+	{
+		title: "[MingY] 迷宫饭 / Dungeon Meshi [24][1080p][CHS&JPN]",
+		want:  Release{Group: "MingY", Series: "迷宫饭", Aliases: []string{"Dungeon Meshi"}, Episode: 24, Version: 1, Resolution: "1080p", Languages: []string{"CHS", "JPN"}},
+	},
+	{
+		// 分类标记不是番剧名
+		title: "[GM-Team][国漫][凡人修仙传][Fan Ren Xiu Xian Zhuan][2023][125][AVC][GB][1080P]",
+		want:  Release{Group: "GM-Team", Series: "凡人修仙传", Aliases: []string{"Fan Ren Xiu Xian Zhuan"}, Episode: 125, Version: 1, Resolution: "1080p", Codec: "AVC", Languages: []string{"CHS"}},
+	},
+	{
+		// 合集带特典，名称末尾紧贴的 - 是名称的一部分
+		title: "[DBD-Raws][86 -Eighty Six-][01-23TV全集+SP][1080P][BDRip][HEVC-10bit][简繁外挂][FLAC][MKV]",
+		want:  Release{Group: "DBD-Raws", Series: "86 -Eighty Six-", Episode: 1, EpisodeEnd: 23, Batch: true, Version: 1, Resolution: "1080p", Codec: "HEVC", Source: "BDRip", Languages: []string{"CHS", "CHT"}},
+	},
+	{
+		title: "[Lilith-Raws] 86 - Eighty Six - 23 [Baha][WEB-DL][1080p][AVC AAC][CHT][MP4]",
+		want:  Release{Group: "Lilith-Raws", Series: "86 - Eighty Six", Episode: 23, Version: 1, Resolution: "1080p", Codec: "AVC", Source: "WEB-DL", Languages: []string{"CHT"}},
+	},
+	{
+		title: "[Snow-Raws] 86―エイティシックス― 第01話 (BD 1920x1080 HEVC-YUV420P10 FLAC)",
+		want:  Release{Group: "Snow-Raws", Series: "86―エイティシックス―", Episode: 1, Version: 1, Resolution: "1080p", Codec: "HEVC", Source: "BDRip"},
+	},
+	{
+		title: "[Kamigami&VCB-Studio] Re:Zero kara Hajimeru Isekai Seikatsu [01-25 Fin][Ma10p_1080p][x265_flac]",
+		want:  Release{Group: "Kamigami&VCB-Studio", Series: "Re:Zero kara Hajimeru Isekai Seikatsu", Episode: 1, EpisodeEnd: 25, Batch: true, Version: 1, Resolution: "1080p", Codec: "HEVC"},
+	},
+	{
+		title: "[黒ネズミたち] 物语系列 / Monogatari Series: Off & Monster Season - 03 (ABEMA 1920x1080 AVC AAC MP4)",
+		want:  Release{Group: "黒ネズミたち", Series: "物语系列", Aliases: []string{"Monogatari Series: Off & Monster Season"}, Episode: 3, Version: 1, Resolution: "1080p", Codec: "AVC"},
+	},
+}
+
+func TestParseRelease(t *testing.T) {
+	for _, tt := range releaseTests {
+		got := *ParseRelease(tt.title)
+		got.Title = ""
+		if len(got.Aliases) == 0 {
+			got.Aliases = nil
+		}
+		if len(got.Languages) == 0 {
+			got.Languages = nil
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRelease(%q)\n got: %+v\nwant: %+v", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestReleaseEpisodeString(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"[ANi] 葬送的芙莉蓮 - 12 [1080P][Baha][WEB-DL][AAC AVC][CHT][MP4]", "12"},
+		{"[DBD-Raws][86 -Eighty Six-][01-23TV全集+SP][1080P][BDRip][HEVC-10bit][简繁外挂][FLAC][MKV]", "01-23"},
+		{"[VCB-Studio] Sousou no Frieren [Ma10p_1080p]", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseRelease(tt.title).EpisodeString(); got != tt.want {
+			t.Errorf("EpisodeString(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestParseChineseNumber(t *testing.T) {
+	tests := map[string]int{"12": 12, "二": 2, "十": 10, "十二": 12, "二十": 20, "二十五": 25, "一百零三": 103}
+	for s, want := range tests {
+		if got := parseChineseNumber(s); got != want {
+			t.Errorf("parseChineseNumber(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// markdownEscapeRegex 转义字符，用于把Markdown标题还原为纯文本
+var markdownEscapeRegex = regexp.MustCompile(`\\(.)`)
+
+// feishuHeaderColors 飞书卡片标题栏的颜色，按事件类型区分
+var feishuHeaderColors = map[string]string{
+	EventNewRelease:   "blue",
+	EventTaskComplete: "green",
+	EventTaskFailed:   "red",
+	EventFeedError:    "orange",
+	EventQuotaWarning: "yellow",
+	EventSummary:      "grey",
+}
+
+// plainMarkdown 去掉Markdown的粗体标记和转义，用于只支持纯文本的标题
+func plainMarkdown(s string) string {
+	return markdownEscapeRegex.ReplaceAllString(strings.ReplaceAll(s, "**", ""), "$1")
+}
+
+// robotResult 企业微信和钉钉机器人的返回结果
+type robotResult struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// WeComOptions 企业微信群机器人的配置
+type WeComOptions struct {
+	URL string `json:"url"` // 机器人的Webhook地址
+}
+
+// RobotOptions 钉钉、飞书群机器人的配置
+type RobotOptions struct {
+	URL    string `json:"url"`    // 机器人的Webhook地址
+	Secret string `json:"secret"` // 钉钉的加签密钥、飞书的签名校验密钥
+}
+
+// decodeRobotOptions 解析钉钉、飞书群机器人的配置
+func decodeRobotOptions(config NotifierConfig) (*RobotOptions, error) {
+	var options RobotOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.URL == "" {
+		return nil, fmt.Errorf("缺少url（Webhook地址）")
+	}
+	return &options, nil
+}
+
+// WeComNotifier 企业微信群机器人，url 为机器人的Webhook地址
+type WeComNotifier struct {
+	name     string
+	webhook  string
+	renderer *MessageRenderer
+}
+
+// newWeComNotifier 根据通知渠道配置创建企业微信机器人通知
+func newWeComNotifier(config NotifierConfig) (Notifier, error) {
+	var options WeComOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.URL == "" {
+		return nil, fmt.Errorf("缺少url（Webhook地址）")
+	}
+
+	renderer, err := NewMessageRenderer(formatCommonMark, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WeComNotifier{name: config.Name, webhook: options.URL, renderer: renderer}, nil
+}
+
+// Name 渠道名称
+func (wn *WeComNotifier) Name() string {
+	return wn.name
+}
+
+// Send 发送Markdown消息
+func (wn *WeComNotifier) Send(event *Event) error {
+	var result robotResult
+	err := postJSON(wn.webhook, map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": wn.renderer.Render(event)},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("企业微信返回错误 (errcode: %d): %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
+
+// DingTalkNotifier 钉钉群机器人，url 为机器人的Webhook地址，配置了 secret 时使用加签
+type DingTalkNotifier struct {
+	name     string
+	webhook  string
+	secret   string
+	renderer *MessageRenderer
+}
+
+// newDingTalkNotifier 根据通知渠道配置创建钉钉机器人通知
+func newDingTalkNotifier(config NotifierConfig) (Notifier, error) {
+	options, err := decodeRobotOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer, err := NewMessageRenderer(formatCommonMark, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DingTalkNotifier{name: config.Name, webhook: options.URL, secret: options.Secret, renderer: renderer}, nil
+}
+
+// Name 渠道名称
+func (dn *DingTalkNotifier) Name() string {
+	return dn.name
+}
+
+// Send 发送Markdown消息，钉钉的Markdown需要空行才会换行
+func (dn *DingTalkNotifier) Send(event *Event) error {
+	message := dn.renderer.Render(event)
+	title, _ := splitTitle(message)
+
+	endpoint := dn.webhook
+	if dn.secret != "" {
+		u, err := url.Parse(dn.webhook)
+		if err != nil {
+			return fmt.Errorf("url 无效: %v", err)
+		}
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		query := u.Query()
+		query.Set("timestamp", timestamp)
+		query.Set("sign", dingTalkSign(dn.secret, timestamp))
+		u.RawQuery = query.Encode()
+		endpoint = u.String()
+	}
+
+	var result robotResult
+	err := postJSON(endpoint, map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": plainMarkdown(title),
+			"text":  strings.ReplaceAll(message, "\n", "\n\n"),
+		},
+	}, &result)
+	if err != nil {
+		return err
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("钉钉返回错误 (errcode: %d): %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
+
+// dingTalkSign 钉钉加签：以secret为密钥，对 timestamp+"\n"+secret 做HMAC-SHA256后Base64编码
+func dingTalkSign(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// FeishuNotifier 飞书/Lark群机器人，url 为机器人的Webhook地址，配置了 secret 时使用签名校验
+type FeishuNotifier struct {
+	name     string
+	webhook  string
+	secret   string
+	renderer *MessageRenderer
+}
+
+// newFeishuNotifier 根据通知渠道配置创建飞书机器人通知
+func newFeishuNotifier(config NotifierConfig) (Notifier, error) {
+	options, err := decodeRobotOptions(config)
+	if err != nil {
+		return nil, err
+	}
+
+	renderer, err := NewMessageRenderer(formatCommonMark, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FeishuNotifier{name: config.Name, webhook: options.URL, secret: options.Secret, renderer: renderer}, nil
+}
+
+// Name 渠道名称
+func (fn *FeishuNotifier) Name() string {
+	return fn.name
+}
+
+// Send 发送消息卡片，消息的第一行作为卡片标题，标题栏颜色按事件类型区分
+func (fn *FeishuNotifier) Send(event *Event) error {
+	title, body := splitTitle(fn.renderer.Render(event))
+
+	color, ok := feishuHeaderColors[event.Type]
+	if !ok {
+		color = "blue"
+	}
+
+	payload := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"config": map[string]bool{"wide_screen_mode": true},
+			"header": map[string]interface{}{
+				"title":    map[string]string{"tag": "plain_text", "content": plainMarkdown(title)},
+				"template": color,
+			},
+			"elements": []interface{}{
+				map[string]interface{}{
+					"tag":  "div",
+					"text": map[string]string{"tag": "lark_md", "content": body},
+				},
+			},
+		},
+	}
+	if fn.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		payload["timestamp"] = timestamp
+		payload["sign"] = feishuSign(fn.secret, timestamp)
+	}
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := postJSON(fn.webhook, payload, &result); err != nil {
+		return err
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("飞书返回错误 (code: %d): %s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+// feishuSign 飞书签名：以 timestamp+"\n"+secret 为密钥，对空字符串做HMAC-SHA256后Base64编码
+func feishuSign(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(timestamp+"\n"+secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Rule 编译后的过滤规则
+//
+// 规则语法：
+//   - 普通文本按不区分大小写的子串匹配，相邻的词会连成一个短语，如 Sousou no Frieren
+//   - "带空格或运算符的文本" 用双引号括起来
+//   - re:<正则> 使用正则匹配（默认不区分大小写），包含空格时写作 re:"<正则>"
+//   - AND / && 、OR / || 、NOT / ! 以及括号，优先级 NOT > AND > OR
+//
+// 例如：1080p AND (简体 OR 简日) AND NOT 合集
+//
+// 不含运算符、引号和 re: 的规则是旧版的普通关键词，按原文做子串匹配，其中的括号和不成对的引号都是文本
+type Rule struct {
+	Source string
+	root   ruleNode
+}
+
+// Match 判断标题是否满足规则
+func (r *Rule) Match(title string) bool {
+	return r.root.match(title, strings.ToLower(title))
+}
+
+func (r *Rule) String() string {
+	return r.Source
+}
+
+// ruleNode 规则语法树节点
+type ruleNode interface {
+	match(title, lower string) bool
+}
+
+type ruleText struct{ text string }
+type ruleRegex struct{ re *regexp.Regexp }
+type ruleNot struct{ node ruleNode }
+type ruleAnd struct{ left, right ruleNode }
+type ruleOr struct{ left, right ruleNode }
+
+func (n ruleText) match(title, lower string) bool  { return strings.Contains(lower, n.text) }
+func (n ruleRegex) match(title, lower string) bool { return n.re.MatchString(title) }
+func (n ruleNot) match(title, lower string) bool   { return !n.node.match(title, lower) }
+func (n ruleAnd) match(title, lower string) bool {
+	return n.left.match(title, lower) && n.right.match(title, lower)
+}
+func (n ruleOr) match(title, lower string) bool {
+	return n.left.match(title, lower) || n.right.match(title, lower)
+}
+
+// ruleTokenKind 词法单元类型
+type ruleTokenKind int
+
+const (
+	ruleTokenWord ruleTokenKind = iota
+	ruleTokenQuoted
+	ruleTokenRegex
+	ruleTokenAnd
+	ruleTokenOr
+	ruleTokenNot
+	ruleTokenLParen
+	ruleTokenRParen
+	ruleTokenEOF
+)
+
+type ruleToken struct {
+	kind ruleTokenKind
+	text string
+	pos  int // 在规则中的位置（从1开始，按字符计）
+}
+
+// CompileRule 编译过滤规则
+func CompileRule(source string) (*Rule, error) {
+	if strings.TrimSpace(source) == "" {
+		return nil, fmt.Errorf("规则为空")
+	}
+	if plainKeyword(source) {
+		return &Rule{Source: source, root: ruleText{strings.ToLower(source)}}, nil
+	}
+
+	tokens, err := lexRule(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &ruleParser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != ruleTokenEOF {
+		return nil, fmt.Errorf("第 %d 个字符处有多余的 %q", tok.pos, tok.text)
+	}
+
+	return &Rule{Source: source, root: root}, nil
+}
+
+// CompileRules 编译一组规则，出错时指出是第几条
+func CompileRules(sources []string) ([]*Rule, error) {
+	rules := make([]*Rule, 0, len(sources))
+	for i, source := range sources {
+		rule, err := CompileRule(source)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 条规则 %q 无效: %v", i+1, source, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// matchAnyRule 任意一条规则匹配即返回该规则
+func matchAnyRule(rules []*Rule, title string) (*Rule, bool) {
+	for _, rule := range rules {
+		if rule.Match(title) {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// plainKeyword 判断规则是否是普通关键词：没有 AND/OR/NOT、&&、||、!、re: 和成对的引号
+func plainKeyword(source string) bool {
+	tokens, err := lexRule(source)
+	if err != nil {
+		// 引号没有闭合时，去掉引号后仍没有运算符的也是普通关键词
+		if tokens, err = lexRule(strings.ReplaceAll(source, `"`, " ")); err != nil {
+			return false
+		}
+	}
+
+	for _, tok := range tokens {
+		switch tok.kind {
+		case ruleTokenAnd, ruleTokenOr, ruleTokenNot, ruleTokenRegex, ruleTokenQuoted:
+			return false
+		}
+	}
+	return true
+}
+
+// lexRule 将规则切分为词法单元
+func lexRule(source string) ([]ruleToken, error) {
+	runes := []rune(source)
+	var tokens []ruleToken
+
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, ruleToken{kind: ruleTokenLParen, text: "(", pos: pos})
+			i++
+		case c == ')':
+			tokens = append(tokens, ruleToken{kind: ruleTokenRParen, text: ")", pos: pos})
+			i++
+		case c == '!':
+			tokens = append(tokens, ruleToken{kind: ruleTokenNot, text: "!", pos: pos})
+			i++
+		case (c == '&' || c == '|') && i+1 < len(runes) && runes[i+1] == c:
+			kind := ruleTokenAnd
+			if c == '|' {
+				kind = ruleTokenOr
+			}
+			tokens = append(tokens, ruleToken{kind: kind, text: string([]rune{c, c}), pos: pos})
+			i += 2
+		case c == '"':
+			text, next, err := lexRuleQuoted(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, ruleToken{kind: ruleTokenQuoted, text: text, pos: pos})
+			i = next
+		case strings.HasPrefix(string(runes[i:]), "re:"):
+			i += 3
+			var pattern string
+			if i < len(runes) && runes[i] == '"' {
+				text, next, err := lexRuleQuoted(runes, i)
+				if err != nil {
+					return nil, err
+				}
+				pattern, i = text, next
+			} else {
+				// 读取到空白或不配对的右括号为止
+				start, depth := i, 0
+				for i < len(runes) && !unicode.IsSpace(runes[i]) {
+					if runes[i] == '(' {
+						depth++
+					} else if runes[i] == ')' {
+						if depth == 0 {
+							break
+						}
+						depth--
+					}
+					i++
+				}
+				pattern = string(runes[start:i])
+			}
+			if pattern == "" {
+				return nil, fmt.Errorf("第 %d 个字符处的 re: 缺少正则表达式", pos)
+			}
+			tokens = append(tokens, ruleToken{kind: ruleTokenRegex, text: pattern, pos: pos})
+		default:
+			// 单个 & 和 | 以及词中间的 ! 属于普通文本，如 Nekomoe kissaten&LoliHouse
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune(`()"`, runes[i]) {
+				if (runes[i] == '&' || runes[i] == '|') && i+1 < len(runes) && runes[i+1] == runes[i] {
+					break
+				}
+				i++
+			}
+			word := string(runes[start:i])
+			switch word {
+			case "AND":
+				tokens = append(tokens, ruleToken{kind: ruleTokenAnd, text: word, pos: pos})
+			case "OR":
+				tokens = append(tokens, ruleToken{kind: ruleTokenOr, text: word, pos: pos})
+			case "NOT":
+				tokens = append(tokens, ruleToken{kind: ruleTokenNot, text: word, pos: pos})
+			default:
+				tokens = append(tokens, ruleToken{kind: ruleTokenWord, text: word, pos: pos})
+			}
+		}
+	}
+
+	tokens = append(tokens, ruleToken{kind: ruleTokenEOF, text: "", pos: len(runes) + 1})
+	return tokens, nil
+}
+
+// lexRuleQuoted 读取双引号括起来的文本，支持 \" 与 \\ 转义
+func lexRuleQuoted(runes []rune, start int) (string, int, error) {
+	var sb strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			if i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+			}
+			sb.WriteRune(runes[i])
+		case '"':
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteRune(runes[i])
+		}
+	}
+	return "", 0, fmt.Errorf("第 %d 个字符处的引号没有闭合", start+1)
+}
+
+// ruleParser 递归下降解析器
+type ruleParser struct {
+	tokens []ruleToken
+	pos    int
+}
+
+func (p *ruleParser) peek() ruleToken {
+	return p.tokens[p.pos]
+}
+
+func (p *ruleParser) next() ruleToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != ruleTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// parseOr or := and (OR and)*
+func (p *ruleParser) parseOr() (ruleNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == ruleTokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleOr{left, right}
+	}
+	return left, nil
+}
+
+// parseAnd and := unary (AND unary)*
+func (p *ruleParser) parseAnd() (ruleNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == ruleTokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = ruleAnd{left, right}
+	}
+	return left, nil
+}
+
+// parseUnary unary := NOT unary | primary
+func (p *ruleParser) parseUnary() (ruleNode, error) {
+	if p.peek().kind == ruleTokenNot {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return ruleNot{node}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary primary := ( or ) | 短语 | "文本" | re:正则
+func (p *ruleParser) parsePrimary() (ruleNode, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case ruleTokenLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != ruleTokenRParen {
+			return nil, fmt.Errorf("第 %d 个字符处的左括号没有闭合", tok.pos)
+		}
+		return node, nil
+	case ruleTokenWord:
+		// 相邻的词连成一个短语
+		words := []string{tok.text}
+		for p.peek().kind == ruleTokenWord {
+			words = append(words, p.next().text)
+		}
+		return ruleText{strings.ToLower(strings.Join(words, " "))}, nil
+	case ruleTokenQuoted:
+		return ruleText{strings.ToLower(tok.text)}, nil
+	case ruleTokenRegex:
+		re, err := regexp.Compile("(?i)" + tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 个字符处的正则表达式无效: %v", tok.pos, err)
+		}
+		return ruleRegex{re}, nil
+	case ruleTokenEOF:
+		return nil, fmt.Errorf("规则意外结束")
+	}
+
+	return nil, fmt.Errorf("第 %d 个字符处不应出现 %q", tok.pos, tok.text)
+}
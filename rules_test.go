@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuleMatch(t *testing.T) {
+	const (
+		frieren = "[LoliHouse] Sousou no Frieren - 12 [WebRip 1080p HEVC-10bit AAC][简繁内封字幕]"
+		spy     = "[ANi] 间谍过家家 第二季 - 01 [720P][Baha][WEB-DL][AAC AVC][CHT][MP4]"
+		batch   = "[Nekomoe kissaten&LoliHouse] Sousou no Frieren [01-28 合集][WebRip 1080p HEVC-10bit AAC ASSx2]"
+	)
+
+	tests := []struct {
+		rule  string
+		title string
+		want  bool
+	}{
+		// 普通关键词
+		{"1080p", frieren, true},
+		{"1080P", frieren, true},
+		{"720p", frieren, false},
+		{"Sousou no Frieren", frieren, true},
+		{"Sousou  no Frieren", frieren, false}, // 普通关键词按原文匹配
+		{"kissaten&LoliHouse", batch, true},
+		{"[ANi] 间谍过家家", spy, true},
+		{"[01-28 合集]", batch, true},
+		{"Frieren (2023)", frieren, false}, // 括号是文本
+		{"[AAC AVC](CHT)", spy, false},
+		{"Nekomoe kissaten&LoliHouse] Sousou", batch, true},
+		{`"Sousou no`, `"Sousou no Frieren"`, true}, // 不成对的引号是文本
+		{"Romeo and Juliet", "Romeo and Juliet - 01", true},
+
+		// 优先级 NOT > AND > OR
+		{"720p OR 1080p AND 合集", frieren, false},
+		{"720p OR 1080p AND 合集", spy, true},
+		{"720p OR 1080p AND 合集", batch, true},
+		{"NOT 合集 AND 1080p", frieren, true},
+		{"NOT 合集 AND 1080p", batch, false},
+		{"NOT 720p OR 合集", spy, false},
+		{"1080p && !合集", frieren, true},
+		{"1080p && !合集", batch, false},
+		{"720p || 合集", frieren, false},
+
+		// 分组
+		{"1080p AND (简繁 OR CHT)", frieren, true},
+		{"1080p AND (简繁 OR CHT)", spy, false},
+		{"(720p OR 1080p) AND NOT (合集 OR Baha)", frieren, true},
+		{"(720p OR 1080p) AND NOT (合集 OR Baha)", spy, false},
+		{"NOT NOT (1080p)", frieren, true},
+		{"((Frieren))AND(1080p)", frieren, true},
+
+		// 引号
+		{`"HEVC-10bit" AND "简繁内封字幕"`, frieren, true},
+		{`"Sousou  no" OR 720p`, frieren, false},
+		{`"Romeo AND Juliet"`, "Romeo AND Juliet - 01", true},
+		{`"Romeo AND Juliet"`, "Romeo - 01 [Juliet]", false},
+		{`"(2023)" AND Frieren`, "Sousou no Frieren (2023) - 12", true},
+		{`"say \"hi\"" OR 720p`, `Anime - say "hi" - 01`, true},
+		{`"a\\b" OR 720p`, `a\b`, true},
+
+		// 正则
+		{`re:"- 1[0-2] "`, frieren, true},
+		{`re:"- 0[1-9] "`, frieren, false},
+		{"re:(?-i)webrip", frieren, false},
+		{"re:WEBRIP", frieren, true},
+		{`re:"Sousou no Frieren - \d+ " AND NOT 合集`, frieren, true},
+		{"(re:第二季|S2) AND 720p", spy, true},
+		{"NOT re:\\[0?1-\\d+", batch, false},
+	}
+
+	for _, tt := range tests {
+		rule, err := CompileRule(tt.rule)
+		if err != nil {
+			t.Errorf("CompileRule(%q): %v", tt.rule, err)
+			continue
+		}
+		if got := rule.Match(tt.title); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.rule, tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestCompileRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule string
+		err  string
+	}{
+		{"", "规则为空"},
+		{"   ", "规则为空"},
+		{"1080p AND", "规则意外结束"},
+		{"NOT", "规则意外结束"},
+		{"OR 1080p", "第 1 个字符处不应出现 \"OR\""},
+		{"1080p AND OR 简体", "第 11 个字符处不应出现 \"OR\""},
+		{"(1080p OR 简体", "第 1 个字符处的左括号没有闭合"},
+		{"1080p AND (简体 OR (简日)", "第 11 个字符处的左括号没有闭合"},
+		{"1080p AND 简体)", "第 13 个字符处有多余的 \")\""},
+		{"NOT 合集 (2023)", "第 8 个字符处有多余的 \"(\""},
+		{`1080p AND "简体`, "第 11 个字符处的引号没有闭合"},
+		{"re: AND 1080p", "第 1 个字符处的 re: 缺少正则表达式"},
+		{"1080p AND re:[简繁", "第 11 个字符处的正则表达式无效"},
+	}
+
+	for _, tt := range tests {
+		_, err := CompileRule(tt.rule)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("CompileRule(%q) error = %v, want containing %q", tt.rule, err, tt.err)
+		}
+	}
+}
+
+func TestCompileRules(t *testing.T) {
+	if _, err := CompileRules([]string{"1080p", "简体 AND (", "720p"}); err == nil || !strings.Contains(err.Error(), "第 2 条规则") {
+		t.Errorf("error = %v, want it to name the second rule", err)
+	}
+
+	rules, err := CompileRules([]string{"720p", "1080p AND NOT 合集"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule, ok := matchAnyRule(rules, "Sousou no Frieren - 12 [1080p]"); !ok || rule.String() != "1080p AND NOT 合集" {
+		t.Errorf("matchAnyRule = %v, %v", rule, ok)
+	}
+	if _, ok := matchAnyRule(rules, "Sousou no Frieren [01-28 合集][1080p]"); ok {
+		t.Error("matchAnyRule matched an excluded title")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Block Kit 的长度限制（字符数），超出时整条消息会被拒绝
+const (
+	slackHeaderLimit  = 150
+	slackSectionLimit = 3000
+	slackFieldLimit   = 2000
+	slackAltTextLimit = 2000
+)
+
+// slackText Block Kit 中的文本对象
+type slackText struct {
+	Type string `json:"type"` // plain_text / mrkdwn
+	Text string `json:"text"`
+}
+
+// SlackOptions Slack渠道的配置
+type SlackOptions struct {
+	URL    string `json:"url"`    // Incoming Webhook地址
+	Images bool   `json:"images"` // 是否在正文旁显示封面
+}
+
+// SlackNotifier 通过Slack Incoming Webhook发送Block Kit消息，url 为Webhook地址
+type SlackNotifier struct {
+	name     string
+	webhook  string
+	images   bool
+	renderer *MessageRenderer
+	escape   func(string) string
+}
+
+// newSlackNotifier 根据通知渠道配置创建Slack通知
+func newSlackNotifier(config NotifierConfig) (Notifier, error) {
+	var options SlackOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.URL == "" {
+		return nil, fmt.Errorf("缺少url（Webhook地址）")
+	}
+
+	renderer, err := NewMessageRenderer(formatSlack, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SlackNotifier{name: config.Name, webhook: options.URL, images: options.Images, renderer: renderer, escape: formatEscapers[formatSlack]}, nil
+}
+
+// Name 渠道名称
+func (sn *SlackNotifier) Name() string {
+	return sn.name
+}
+
+// WantsImages 是否在消息中附带图片
+func (sn *SlackNotifier) WantsImages() bool {
+	return sn.images
+}
+
+// Send 发送Block Kit消息：模板第一行作为标题块，其余作为正文，字幕组、集数、分辨率作为字段，封面作为正文旁的图片
+func (sn *SlackNotifier) Send(event *Event) error {
+	message := sn.renderer.Render(event)
+	// Slack 拒绝文本为空的块：模板只有一行时 splitTitle 以标题作为正文，模板渲染为空时使用事件标题
+	if strings.TrimSpace(message) == "" {
+		message = sn.escape(event.Title)
+	}
+	title, body := splitTitle(message)
+
+	section := map[string]interface{}{
+		"type": "section",
+		"text": slackText{Type: "mrkdwn", Text: truncateRunes(body, slackSectionLimit)},
+	}
+	if sn.images && event.ImageURL != "" {
+		section["accessory"] = map[string]string{"type": "image", "image_url": event.ImageURL, "alt_text": truncateRunes(event.Title, slackAltTextLimit)}
+	}
+
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "header",
+			"text": slackText{Type: "plain_text", Text: truncateRunes(slackPlainText(title), slackHeaderLimit)},
+		},
+		section,
+	}
+
+	if fields := releaseFields(event); len(fields) > 0 {
+		var texts []slackText
+		for _, field := range fields {
+			prefix := "*" + field.Name + "*\n"
+			texts = append(texts, slackText{Type: "mrkdwn", Text: prefix + sn.escapeTruncated(field.Value, slackFieldLimit-utf8.RuneCountInString(prefix))})
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": texts})
+	}
+
+	// text 用于通知预览和不支持 blocks 的客户端
+	return postJSON(sn.webhook, map[string]interface{}{"text": message, "blocks": blocks}, nil)
+}
+
+// escapeTruncated 转义后不超过 limit 个字符；按原文逐字转义，不会把 &amp; 等实体截断
+func (sn *SlackNotifier) escapeTruncated(s string, limit int) string {
+	if escaped := sn.escape(s); utf8.RuneCountInString(escaped) <= limit {
+		return escaped
+	}
+
+	var sb strings.Builder
+	length := 0
+	for _, r := range s {
+		escaped := sn.escape(string(r))
+		n := utf8.RuneCountInString(escaped)
+		if length+n > limit-1 {
+			break
+		}
+		sb.WriteString(escaped)
+		length += n
+	}
+	return sb.String() + "…"
+}
+
+// slackPlainText 去掉mrkdwn的粗体标记和转义，用于纯文本的标题块
+func slackPlainText(s string) string {
+	return strings.NewReplacer("*", "", "&amp;", "&", "&lt;", "<", "&gt;", ">").Replace(s)
+}
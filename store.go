@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// seenStoreFile 已见项目记录文件名，每行一条JSON记录
+const seenStoreFile = "seen.jsonl"
+
+// SeenRecord 已见项目记录
+type SeenRecord struct {
+	GUID         string    `json:"guid"`
+	InfoHash     string    `json:"infohash,omitempty"`
+	Title        string    `json:"title"`
+	Feed         string    `json:"feed,omitempty"`
+	Subscription string    `json:"subscription,omitempty"`
+	SeenAt       time.Time `json:"seen_at"`
+	SubmittedAt  time.Time `json:"submitted_at,omitzero"`
+	TaskID       string    `json:"task_id,omitempty"`
+	Attempts     int       `json:"attempts,omitempty"` // 提交下载任务失败的次数，未放弃时只计数，不算已见
+	Failed       bool      `json:"failed,omitempty"`   // 多次提交失败后放弃
+	Error        string    `json:"error,omitempty"`    // 最后一次提交失败的原因
+}
+
+// pending 提交失败但还会重试的记录
+func (r *SeenRecord) pending() bool {
+	return r.Attempts > 0 && !r.Failed
+}
+
+// SeenStore 基于追加日志的已见项目存储，重启后依然有效
+type SeenStore struct {
+	path    string
+	file    *os.File
+	records map[string]*SeenRecord
+	hashes  map[string]*SeenRecord
+	tasks   map[string]*SeenRecord
+	feeds   map[string]bool
+	retries map[string]int // GUID -> 提交失败的次数
+	mutex   sync.RWMutex
+}
+
+// NewSeenStore 打开（或创建）数据目录下的已见项目存储
+func NewSeenStore(dataDir string) (*SeenStore, error) {
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建数据目录失败: %v", err)
+	}
+
+	store := &SeenStore{
+		path:    filepath.Join(dataDir, seenStoreFile),
+		records: make(map[string]*SeenRecord),
+		hashes:  make(map[string]*SeenRecord),
+		tasks:   make(map[string]*SeenRecord),
+		feeds:   make(map[string]bool),
+		retries: make(map[string]int),
+	}
+
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(store.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开已见项目存储失败: %v", err)
+	}
+	store.file = file
+
+	log.Printf("💾 已加载 %d 条已见项目记录: %s", len(store.records), store.path)
+	return store, nil
+}
+
+// load 读取已有的记录，同一GUID以最后一条为准
+func (s *SeenStore) load() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取已见项目存储失败: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var record SeenRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			// 进程被强制终止时最后一行可能不完整，跳过即可
+			log.Printf("⚠️  跳过损坏的记录 (第 %d 行): %v", lineNo, err)
+			continue
+		}
+		s.index(&record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("读取已见项目存储失败: %v", err)
+	}
+
+	return nil
+}
+
+// index 将记录加入内存索引，调用方需持有锁
+func (s *SeenStore) index(record *SeenRecord) {
+	if record.Feed != "" {
+		s.feeds[record.Feed] = true
+	}
+	if record.pending() {
+		s.retries[record.GUID] = record.Attempts
+		return
+	}
+	delete(s.retries, record.GUID)
+
+	s.records[record.GUID] = record
+	if record.InfoHash != "" {
+		// 同一个种子优先保留已提交任务的记录
+		if existing, ok := s.hashes[record.InfoHash]; !ok || existing.TaskID == "" {
+			s.hashes[record.InfoHash] = record
+		}
+	}
+	if record.TaskID != "" {
+		s.tasks[record.TaskID] = record
+	}
+}
+
+// Seen 判断GUID是否已经处理过
+func (s *SeenStore) Seen(guid string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	_, ok := s.records[guid]
+	return ok
+}
+
+// Attempts 返回GUID提交下载任务失败的次数
+func (s *SeenStore) Attempts(guid string) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.retries[guid]
+}
+
+// SeenInfoHash 返回同一infohash的已有记录，不同RSS源的同一个种子会共用该记录
+func (s *SeenStore) SeenInfoHash(infoHash string) (*SeenRecord, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	record, ok := s.hashes[infoHash]
+	return record, ok
+}
+
+// SeenTask 返回提交了该下载任务的记录
+func (s *SeenStore) SeenTask(taskID string) (*SeenRecord, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	record, ok := s.tasks[taskID]
+	return record, ok
+}
+
+// HasFeed 判断该RSS源是否已经有过记录
+func (s *SeenStore) HasFeed(feed string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.feeds[feed]
+}
+
+// Add 追加一条记录并立即写入磁盘
+func (s *SeenStore) Add(record SeenRecord) error {
+	if record.SeenAt.IsZero() {
+		record.SeenAt = time.Now()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("编码记录失败: %v", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入已见项目存储失败: %v", err)
+	}
+	s.index(&record)
+
+	return nil
+}
+
+// Close 关闭存储文件
+func (s *SeenStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.file.Close()
+}
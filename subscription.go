@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// subscriptionOverridesFile 通过聊天命令修改的订阅，保存在数据目录中（config.json 可能是只读挂载）
+const subscriptionOverridesFile = "subscriptions.json"
+
+// SubscriptionOverrides 运行时对订阅的修改，启动时叠加到 config.json 中的订阅上
+type SubscriptionOverrides struct {
+	Added   []Subscription `json:"added"`   // 通过命令新增的订阅
+	Removed []string       `json:"removed"` // 通过命令删除的 config.json 中的订阅名称
+	// Muted 订阅名称 -> 屏蔽的番剧名，通过通知上的按钮屏蔽，之后不再下载
+	Muted map[string][]string `json:"muted,omitempty"`
+}
+
+// loadSubscriptions 汇总所有启用的订阅
+// 旧的 rss 配置块中的每个RSS源会作为隐式订阅，共用 rss 中的过滤规则和 pikpak 中的下载目录
+func loadSubscriptions(config *Config) ([]*Subscription, error) {
+	var subs []*Subscription
+	names := make(map[string]bool)
+
+	for i := range config.Subscriptions {
+		sub := config.Subscriptions[i]
+		if sub.URL == "" {
+			return nil, fmt.Errorf("第 %d 个订阅缺少url", i+1)
+		}
+		if sub.Name == "" {
+			sub.Name = sub.URL
+		}
+		if names[sub.Name] {
+			return nil, fmt.Errorf("订阅名称重复: %s", sub.Name)
+		}
+		names[sub.Name] = true
+
+		if err := sub.compileRules(); err != nil {
+			return nil, err
+		}
+		if err := sub.compileOrganizeTemplates(); err != nil {
+			return nil, err
+		}
+
+		if sub.Enabled {
+			subs = append(subs, &sub)
+		}
+	}
+
+	for _, url := range config.RSS.URLs {
+		if names[url] {
+			continue
+		}
+		names[url] = true
+
+		sub := &Subscription{
+			Name:            url,
+			URL:             url,
+			Enabled:         true,
+			Keywords:        config.RSS.Keywords,
+			ExcludeKeywords: config.RSS.ExcludeKeywords,
+			Resolutions:     config.RSS.Resolutions,
+		}
+		if err := sub.compileRules(); err != nil {
+			return nil, err
+		}
+
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+// compileRules 编译订阅的包含和排除规则
+func (sub *Subscription) compileRules() error {
+	var err error
+
+	sub.includeRules, err = CompileRules(sub.Keywords)
+	if err != nil {
+		return fmt.Errorf("订阅 [%s] 的 keywords 中%v", sub.Name, err)
+	}
+
+	sub.excludeRules, err = CompileRules(sub.ExcludeKeywords)
+	if err != nil {
+		return fmt.Errorf("订阅 [%s] 的 exclude_keywords 中%v", sub.Name, err)
+	}
+
+	return nil
+}
+
+// matchGroup 判断字幕组是否在首选字幕组列表中，联合字幕组（如 A&B）只要包含其一即可
+func (sub *Subscription) matchGroup(group string) bool {
+	if len(sub.Groups) == 0 {
+		return true
+	}
+
+	group = strings.ToLower(group)
+	for _, preferred := range sub.Groups {
+		if preferred != "" && strings.Contains(group, strings.ToLower(preferred)) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptionFolder 获取订阅在下载器中的下载位置，未单独配置时使用下载器的默认目录
+// folder_id 只对PikPak有效，本地下载器使用 folder_path
+func subscriptionFolder(sub *Subscription, downloader Downloader) (string, error) {
+	if sub.FolderID != "" && downloader.Name() == pikpakDownloaderName {
+		return sub.FolderID, nil
+	}
+	return downloader.ResolveFolder(sub.FolderPath)
+}
+
+// loadSubscriptionOverrides 读取数据目录中保存的订阅修改
+func loadSubscriptionOverrides(dataDir string) (*SubscriptionOverrides, error) {
+	overrides := &SubscriptionOverrides{}
+
+	data, err := os.ReadFile(subscriptionOverridesPath(dataDir))
+	if os.IsNotExist(err) {
+		return overrides, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取订阅修改失败: %v", err)
+	}
+
+	if err := json.Unmarshal(data, overrides); err != nil {
+		return nil, fmt.Errorf("解析订阅修改失败: %v", err)
+	}
+	return overrides, nil
+}
+
+// save 保存订阅修改
+func (so *SubscriptionOverrides) save(dataDir string) error {
+	data, err := json.MarshalIndent(so, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码订阅修改失败: %v", err)
+	}
+
+	path := subscriptionOverridesPath(dataDir)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入订阅修改失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("写入订阅修改失败: %v", err)
+	}
+	return nil
+}
+
+// subscriptionOverridesPath 订阅修改文件的路径
+func subscriptionOverridesPath(dataDir string) string {
+	if dataDir == "" {
+		dataDir = "data"
+	}
+	return filepath.Join(dataDir, subscriptionOverridesFile)
+}
+
+// apply 将订阅修改叠加到配置中的订阅上
+func (so *SubscriptionOverrides) apply(subs []*Subscription) ([]*Subscription, error) {
+	removed := make(map[string]bool, len(so.Removed))
+	for _, name := range so.Removed {
+		removed[name] = true
+	}
+
+	var result []*Subscription
+	names := make(map[string]bool)
+	for _, sub := range subs {
+		if removed[sub.Name] {
+			continue
+		}
+		names[sub.Name] = true
+		result = append(result, sub)
+	}
+
+	for i := range so.Added {
+		sub := so.Added[i]
+		if names[sub.Name] {
+			continue
+		}
+		if err := sub.compileRules(); err != nil {
+			return nil, err
+		}
+		if err := sub.compileOrganizeTemplates(); err != nil {
+			return nil, err
+		}
+		names[sub.Name] = true
+		result = append(result, &sub)
+	}
+
+	return result, nil
+}
+
+// subscriptionList 返回当前订阅列表的副本，可以在检查RSS的同时通过命令修改订阅
+func (bm *BangumiMonitor) subscriptionList() []*Subscription {
+	bm.subsMutex.RLock()
+	defer bm.subsMutex.RUnlock()
+
+	return append([]*Subscription(nil), bm.subscriptions...)
+}
+
+// addSubscription 新增订阅并保存
+func (bm *BangumiMonitor) addSubscription(sub *Subscription) error {
+	if err := sub.compileRules(); err != nil {
+		return err
+	}
+	if err := sub.compileOrganizeTemplates(); err != nil {
+		return err
+	}
+
+	bm.subsMutex.Lock()
+	defer bm.subsMutex.Unlock()
+
+	for _, existing := range bm.subscriptions {
+		if existing.Name == sub.Name {
+			return fmt.Errorf("订阅名称重复: %s", sub.Name)
+		}
+		if existing.URL == sub.URL {
+			return fmt.Errorf("该RSS源已被订阅: %s", existing.Name)
+		}
+	}
+
+	bm.overrides.Added = append(bm.overrides.Added, *sub)
+	if err := bm.overrides.save(bm.config.DataDir); err != nil {
+		bm.overrides.Added = bm.overrides.Added[:len(bm.overrides.Added)-1]
+		return err
+	}
+
+	bm.subscriptions = append(bm.subscriptions, sub)
+	return nil
+}
+
+// removeSubscription 删除订阅并保存
+func (bm *BangumiMonitor) removeSubscription(name string) error {
+	bm.subsMutex.Lock()
+	defer bm.subsMutex.Unlock()
+
+	index := -1
+	for i, sub := range bm.subscriptions {
+		if sub.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("未找到订阅: %s", name)
+	}
+
+	// 命令新增的订阅直接删掉，config.json 中的订阅记录为已删除
+	added := bm.overrides.Added[:0:0]
+	for _, sub := range bm.overrides.Added {
+		if sub.Name != name {
+			added = append(added, sub)
+		}
+	}
+	previous := *bm.overrides
+	if len(added) == len(bm.overrides.Added) {
+		bm.overrides.Removed = append(bm.overrides.Removed, name)
+	}
+	bm.overrides.Added = added
+
+	if err := bm.overrides.save(bm.config.DataDir); err != nil {
+		*bm.overrides = previous
+		return err
+	}
+
+	bm.subscriptions = append(bm.subscriptions[:index:index], bm.subscriptions[index+1:]...)
+	return nil
+}
+
+// muteSeries 屏蔽订阅中的某部番剧并保存
+func (bm *BangumiMonitor) muteSeries(subName, series string) error {
+	bm.subsMutex.Lock()
+	defer bm.subsMutex.Unlock()
+
+	previous := bm.overrides.Muted[subName]
+	for _, muted := range previous {
+		if strings.EqualFold(muted, series) {
+			return nil
+		}
+	}
+
+	if bm.overrides.Muted == nil {
+		bm.overrides.Muted = make(map[string][]string)
+	}
+	bm.overrides.Muted[subName] = append(previous[:len(previous):len(previous)], series)
+
+	if err := bm.overrides.save(bm.config.DataDir); err != nil {
+		if previous == nil {
+			delete(bm.overrides.Muted, subName)
+		} else {
+			bm.overrides.Muted[subName] = previous
+		}
+		return err
+	}
+	return nil
+}
+
+// unmuteSeries 在所有订阅中取消屏蔽番剧并保存，返回涉及的订阅名称
+func (bm *BangumiMonitor) unmuteSeries(series string) ([]string, error) {
+	bm.subsMutex.Lock()
+	defer bm.subsMutex.Unlock()
+
+	muted := make(map[string][]string, len(bm.overrides.Muted))
+	var affected []string
+	for subName, list := range bm.overrides.Muted {
+		var kept []string
+		for _, name := range list {
+			if !strings.EqualFold(name, series) {
+				kept = append(kept, name)
+			}
+		}
+		if len(kept) != len(list) {
+			affected = append(affected, subName)
+		}
+		if len(kept) > 0 {
+			muted[subName] = kept
+		}
+	}
+	if len(affected) == 0 {
+		return nil, fmt.Errorf("未屏蔽该番剧: %s", series)
+	}
+
+	previous := bm.overrides.Muted
+	bm.overrides.Muted = muted
+	if err := bm.overrides.save(bm.config.DataDir); err != nil {
+		bm.overrides.Muted = previous
+		return nil, err
+	}
+	return affected, nil
+}
+
+// mutedSeries 返回订阅中屏蔽的番剧
+func (bm *BangumiMonitor) mutedSeries(subName string) []string {
+	bm.subsMutex.RLock()
+	defer bm.subsMutex.RUnlock()
+
+	return append([]string(nil), bm.overrides.Muted[subName]...)
+}
+
+// isMuted 判断番剧是否在订阅中被屏蔽
+func (bm *BangumiMonitor) isMuted(subName, series string) bool {
+	if series == "" {
+		return false
+	}
+	for _, muted := range bm.mutedSeries(subName) {
+		if strings.EqualFold(muted, series) {
+			return true
+		}
+	}
+	return false
+}
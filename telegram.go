@@ -1,19 +1,51 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"log"
+	"strings"
 )
 
+// telegramParseModes parse_mode 配置 -> 消息格式和Telegram的ParseMode
+var telegramParseModes = map[string]struct {
+	format    string
+	parseMode string
+}{
+	"":           {formatMarkdownV2, tgbotapi.ModeMarkdownV2},
+	"markdownv2": {formatMarkdownV2, tgbotapi.ModeMarkdownV2},
+	"markdown":   {formatMarkdown, tgbotapi.ModeMarkdown},
+	"html":       {formatHTML, tgbotapi.ModeHTML},
+	"plain":      {formatText, ""},
+}
+
 type TelegramNotifier struct {
-	bot    *tgbotapi.BotAPI
-	chatID int64
+	name         string
+	bot          *tgbotapi.BotAPI
+	chatID       int64
+	parseMode    string
+	renderer     *MessageRenderer
+	plain        *MessageRenderer // Telegram无法解析格式时使用的纯文本模板
+	commands     bool
+	allowedChats map[int64]bool
 }
 
+// telegramCallbackDataLimit Telegram按钮回调数据的最大长度
+const telegramCallbackDataLimit = 64
+
+// SendMessage 按配置的ParseMode发送消息
 func (tn *TelegramNotifier) SendMessage(message string) error {
+	return tn.send(message, tn.parseMode, nil)
+}
+
+// send 发送消息，parseMode 为空时发送纯文本，keyboard 可以为空
+func (tn *TelegramNotifier) send(message, parseMode string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
 	msg := tgbotapi.NewMessage(tn.chatID, message)
-	msg.ParseMode = "Markdown"
+	msg.ParseMode = parseMode
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
 
 	_, err := tn.bot.Send(msg)
 	if err != nil {
@@ -23,15 +55,242 @@ func (tn *TelegramNotifier) SendMessage(message string) error {
 	return nil
 }
 
-func NewTelegramNotifier(token string, chatID int64) *TelegramNotifier {
+// Name 渠道名称
+func (tn *TelegramNotifier) Name() string {
+	return tn.name
+}
+
+// Send 发送事件通知，Telegram无法解析消息格式时改为发送纯文本，避免通知丢失
+func (tn *TelegramNotifier) Send(event *Event) error {
+	keyboard := tn.eventKeyboard(event)
+	err := tn.send(tn.renderer.Render(event), tn.parseMode, keyboard)
+	if err == nil || tn.parseMode == "" || !isTelegramEntityError(err) {
+		return err
+	}
+
+	log.Printf("⚠️  Telegram无法解析消息格式，改为发送纯文本: %v", err)
+	return tn.send(tn.plain.Render(event), "", keyboard)
+}
+
+// eventKeyboard 新番剧通知上的操作按钮，按钮由命令循环处理，未启用命令时不添加
+func (tn *TelegramNotifier) eventKeyboard(event *Event) *tgbotapi.InlineKeyboardMarkup {
+	if !tn.commands || event.Type != EventNewRelease || event.TaskID == "" {
+		return nil
+	}
+	if len(callbackData(ActionCancel, event.TaskID)) > telegramCallbackDataLimit {
+		return nil
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑️ 取消下载", callbackData(ActionCancel, event.TaskID)),
+			tgbotapi.NewInlineKeyboardButtonData("🔄 重试", callbackData(ActionRetry, event.TaskID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔇 屏蔽该番剧", callbackData(ActionMute, event.TaskID)),
+		),
+	)
+	return &keyboard
+}
+
+// callbackData 按钮回调数据，格式为 操作:任务ID
+func callbackData(action, taskID string) string {
+	return action + ":" + taskID
+}
+
+// isTelegramEntityError 判断是否为消息格式解析错误
+func isTelegramEntityError(err error) bool {
+	return strings.Contains(err.Error(), "can't parse entities")
+}
+
+func NewTelegramNotifier(token string, chatID int64) (*TelegramNotifier, error) {
 	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
-		log.Printf("❌ 创建Telegram Bot失败: %v", err)
-		return nil
+		return nil, fmt.Errorf("创建Telegram Bot失败: %v", err)
 	}
 
+	renderer, _ := NewMessageRenderer(formatMarkdownV2, nil)
+	plain, _ := NewMessageRenderer(formatText, nil)
+
 	return &TelegramNotifier{
-		bot:    bot,
-		chatID: chatID,
+		name:      "telegram",
+		bot:       bot,
+		chatID:    chatID,
+		parseMode: tgbotapi.ModeMarkdownV2,
+		renderer:  renderer,
+		plain:     plain,
+	}, nil
+}
+
+// TelegramOptions Telegram渠道的配置
+type TelegramOptions struct {
+	Token  string `json:"token"`
+	ChatID int64  `json:"chat_id"`
+	// ParseMode 消息格式：MarkdownV2（默认）、Markdown、HTML、plain
+	ParseMode string `json:"parse_mode"`
+	// Commands 是否接收命令，chat_id 和 allowed_chats 中的聊天可以发送命令
+	Commands     bool    `json:"commands"`
+	AllowedChats []int64 `json:"allowed_chats"`
+}
+
+// newTelegramNotifier 根据通知渠道配置创建Telegram通知
+func newTelegramNotifier(config NotifierConfig) (Notifier, error) {
+	var options TelegramOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+	if options.Token == "" || options.ChatID == 0 {
+		return nil, fmt.Errorf("缺少token或chat_id")
+	}
+
+	mode, ok := telegramParseModes[strings.ToLower(options.ParseMode)]
+	if !ok {
+		return nil, fmt.Errorf("parse_mode 不支持: %s", options.ParseMode)
+	}
+
+	renderer, err := NewMessageRenderer(mode.format, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+	// 无法解析格式时按纯文本发送，同样使用配置的模板
+	plain, err := NewMessageRenderer(formatText, config.Templates)
+	if err != nil {
+		return nil, err
+	}
+
+	tn, err := NewTelegramNotifier(options.Token, options.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	tn.name = config.Name
+	tn.parseMode = mode.parseMode
+	tn.renderer = renderer
+	tn.plain = plain
+	tn.commands = options.Commands
+	tn.allowedChats = map[int64]bool{options.ChatID: true}
+	for _, chatID := range options.AllowedChats {
+		tn.allowedChats[chatID] = true
+	}
+	return tn, nil
+}
+
+// Listen 通过长轮询接收命令，只处理白名单中的聊天发来的命令
+func (tn *TelegramNotifier) Listen(ctx context.Context, handler CommandHandler) {
+	if !tn.commands {
+		return
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+	updates := tn.bot.GetUpdatesChan(u)
+
+	log.Printf("🤖 Telegram命令已启用 [%s]: @%s", tn.name, tn.bot.Self.UserName)
+
+	for {
+		select {
+		case <-ctx.Done():
+			tn.bot.StopReceivingUpdates()
+			log.Printf("👋 Telegram命令已停止 [%s]", tn.name)
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			if query := update.CallbackQuery; query != nil && query.Message != nil {
+				if !tn.allowedChats[query.Message.Chat.ID] {
+					log.Printf("🚫 忽略未授权聊天的按钮: %s (chat: %d)", query.Data, query.Message.Chat.ID)
+					continue
+				}
+				go tn.handleCallback(ctx, handler, query)
+				continue
+			}
+			if update.Message == nil || !update.Message.IsCommand() {
+				continue
+			}
+
+			msg := update.Message
+			if !tn.allowedChats[msg.Chat.ID] {
+				log.Printf("🚫 忽略未授权聊天的命令: /%s (chat: %d)", msg.Command(), msg.Chat.ID)
+				continue
+			}
+
+			// 命令可能比较慢（如 /check），不阻塞接收
+			go tn.handleCommand(ctx, handler, msg)
+		}
+	}
+}
+
+// handleCommand 执行命令并回复，回复使用纯文本避免转义问题
+func (tn *TelegramNotifier) handleCommand(ctx context.Context, handler CommandHandler, msg *tgbotapi.Message) {
+	from := fmt.Sprint(msg.Chat.ID)
+	if msg.From != nil {
+		from = msg.From.UserName
+	}
+
+	reply := handler.HandleCommand(ctx, &Command{
+		Name: strings.ToLower(msg.Command()),
+		Args: strings.Fields(msg.CommandArguments()),
+		From: "telegram:" + from,
+	})
+
+	response := tgbotapi.NewMessage(msg.Chat.ID, reply)
+	response.ReplyToMessageID = msg.MessageID
+	if _, err := tn.bot.Send(response); err != nil {
+		log.Printf("❌ 回复Telegram命令失败: %v", err)
+	}
+}
+
+// handleCallback 执行按钮操作，成功后在原消息末尾追加新状态，并去掉已完成的按钮
+func (tn *TelegramNotifier) handleCallback(ctx context.Context, handler CommandHandler, query *tgbotapi.CallbackQuery) {
+	action, taskID, ok := strings.Cut(query.Data, ":")
+	if !ok {
+		tn.answerCallback(tgbotapi.NewCallbackWithAlert(query.ID, "❌ 无效的按钮"))
+		return
+	}
+
+	state, err := handler.HandleAction(ctx, action, taskID)
+	if err != nil {
+		log.Printf("❌ 按钮操作失败: %v", err)
+		tn.answerCallback(tgbotapi.NewCallbackWithAlert(query.ID, fmt.Sprintf("❌ %v", err)))
+		return
+	}
+	tn.answerCallback(tgbotapi.NewCallback(query.ID, state))
+
+	// 追加在末尾的文字不影响原有格式实体的位置，保留实体即可保持原来的格式
+	msg := query.Message
+	edit := tgbotapi.NewEditMessageTextAndMarkup(msg.Chat.ID, msg.MessageID,
+		msg.Text+"\n\n"+state, remainingKeyboard(msg.ReplyMarkup, action, query.Data))
+	edit.Entities = msg.Entities
+	if _, err := tn.bot.Send(edit); err != nil {
+		log.Printf("❌ 更新Telegram消息失败: %v", err)
+	}
+}
+
+// answerCallback 回应按钮点击，让客户端停止加载动画
+func (tn *TelegramNotifier) answerCallback(callback tgbotapi.CallbackConfig) {
+	if _, err := tn.bot.Request(callback); err != nil {
+		log.Printf("❌ 回应Telegram按钮失败: %v", err)
+	}
+}
+
+// remainingKeyboard 去掉已执行的按钮，取消下载后不再保留任何按钮，重试可以多次执行
+func remainingKeyboard(keyboard *tgbotapi.InlineKeyboardMarkup, action, data string) tgbotapi.InlineKeyboardMarkup {
+	remaining := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	if keyboard == nil || action == ActionCancel {
+		return remaining
+	}
+
+	for _, row := range keyboard.InlineKeyboard {
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, button := range row {
+			if action != ActionRetry && button.CallbackData != nil && *button.CallbackData == data {
+				continue
+			}
+			buttons = append(buttons, button)
+		}
+		if len(buttons) > 0 {
+			remaining.InlineKeyboard = append(remaining.InlineKeyboard, buttons)
+		}
 	}
+	return remaining
 }
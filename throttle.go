@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// batchMaxEvents 一条汇总消息最多合并的事件数，超出的留到下一条
+const batchMaxEvents = 50
+
+// QuietHours 每天的免打扰时段，结束时间早于开始时间时表示跨过午夜（如 23:00-08:00）
+type QuietHours struct {
+	start time.Duration // 距离零点的时间
+	end   time.Duration
+}
+
+// parseQuietHours 解析 HH:MM-HH:MM 格式的免打扰时段
+func parseQuietHours(s string) (*QuietHours, error) {
+	var startHour, startMinute, endHour, endMinute int
+	if _, err := fmt.Sscanf(s, "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute); err != nil {
+		return nil, fmt.Errorf("格式应为 HH:MM-HH:MM: %s", s)
+	}
+	for _, v := range []struct{ hour, minute int }{{startHour, startMinute}, {endHour, endMinute}} {
+		if v.hour < 0 || v.hour > 24 || v.minute < 0 || v.minute > 59 || (v.hour == 24 && v.minute != 0) {
+			return nil, fmt.Errorf("时间无效: %s", s)
+		}
+	}
+
+	qh := &QuietHours{
+		start: time.Duration(startHour)*time.Hour + time.Duration(startMinute)*time.Minute,
+		end:   time.Duration(endHour)*time.Hour + time.Duration(endMinute)*time.Minute,
+	}
+	if qh.start == qh.end {
+		return nil, fmt.Errorf("开始和结束时间相同: %s", s)
+	}
+	return qh, nil
+}
+
+// Until 当前处于免打扰时段时返回时段的结束时间
+func (qh *QuietHours) Until(now time.Time) (time.Time, bool) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+
+	if qh.start < qh.end {
+		if offset >= qh.start && offset < qh.end {
+			return midnight.Add(qh.end), true
+		}
+		return time.Time{}, false
+	}
+
+	// 跨过午夜的时段
+	if offset >= qh.start {
+		return midnight.AddDate(0, 0, 1).Add(qh.end), true
+	}
+	if offset < qh.end {
+		return midnight.Add(qh.end), true
+	}
+	return time.Time{}, false
+}
+
+// tokenBucket 令牌桶限速，令牌按固定速率补充，最多积攒 burst 个
+type tokenBucket struct {
+	rate   float64 // 每秒补充的令牌数
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket 创建每分钟 perMinute 条、最多连续发送 burst 条的令牌桶
+func newTokenBucket(perMinute, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(perMinute) / 60,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// refill 补充从上次到现在的令牌
+func (tb *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(tb.last).Seconds(); elapsed > 0 {
+		tb.tokens += elapsed * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+	}
+	tb.last = now
+}
+
+// Wait 距离有可用令牌还需等待的时间
+func (tb *tokenBucket) Wait(now time.Time) time.Duration {
+	tb.refill(now)
+	if tb.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tb.tokens) / tb.rate * float64(time.Second))
+}
+
+// Take 取走一个令牌
+func (tb *tokenBucket) Take(now time.Time) {
+	tb.refill(now)
+	tb.tokens--
+}
+
+// notificationQueue 单个渠道的发送队列，负责批量合并、限速和免打扰
+// 等待发送期间到达的事件会合并成一条汇总消息
+type notificationQueue struct {
+	notifier Notifier
+	window   time.Duration // 批量发送窗口，第一个事件到达后等待这么久再发送
+	bucket   *tokenBucket  // 为空时不限速
+	quiet    *QuietHours   // 为空时没有免打扰时段
+	outbox   *Outbox       // 发送失败的事件放入重试队列，为空时只记录日志
+
+	pending  []*Event
+	deadline time.Time // 批量发送窗口结束的时间
+	mutex    sync.Mutex
+	wake     chan struct{}
+}
+
+// newNotificationQueue 根据渠道配置创建发送队列，没有配置批量发送、限速和免打扰时返回 nil
+func newNotificationQueue(notifier Notifier, config NotifierConfig) (*notificationQueue, error) {
+	if config.BatchSeconds == 0 && config.RateLimit == 0 && config.QuietHours == "" {
+		return nil, nil
+	}
+	if config.BatchSeconds < 0 || config.RateLimit < 0 || config.Burst < 0 {
+		return nil, fmt.Errorf("batch_seconds、rate_limit 和 burst 不能为负数")
+	}
+
+	queue := &notificationQueue{
+		notifier: notifier,
+		window:   time.Duration(config.BatchSeconds) * time.Second,
+		wake:     make(chan struct{}, 1),
+	}
+
+	if config.RateLimit > 0 {
+		burst := config.Burst
+		if burst == 0 {
+			burst = config.RateLimit
+		}
+		queue.bucket = newTokenBucket(config.RateLimit, burst)
+	}
+
+	if config.QuietHours != "" {
+		quiet, err := parseQuietHours(config.QuietHours)
+		if err != nil {
+			return nil, fmt.Errorf("quiet_hours 无效: %v", err)
+		}
+		queue.quiet = quiet
+	}
+
+	return queue, nil
+}
+
+// Push 加入待发送的事件，不会阻塞
+func (nq *notificationQueue) Push(event *Event) {
+	nq.mutex.Lock()
+	if len(nq.pending) == 0 {
+		nq.deadline = time.Now().Add(nq.window)
+	}
+	nq.pending = append(nq.pending, event)
+	nq.mutex.Unlock()
+
+	select {
+	case nq.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run 按批量窗口、限速和免打扰时段发送队列中的事件，ctx 取消时发送剩余的事件
+func (nq *notificationQueue) Run(ctx context.Context) {
+	for {
+		wait, ok := nq.nextDelivery(time.Now())
+		if ok && wait <= 0 {
+			nq.deliver(time.Now())
+			continue
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if ok {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			nq.shutdown()
+			return
+		case <-nq.wake:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// nextDelivery 距离下一次可以发送的时间，队列为空时返回 false
+func (nq *notificationQueue) nextDelivery(now time.Time) (time.Duration, bool) {
+	nq.mutex.Lock()
+	defer nq.mutex.Unlock()
+
+	if len(nq.pending) == 0 {
+		return 0, false
+	}
+
+	at := nq.deadline
+	if nq.quiet != nil {
+		if until, ok := nq.quiet.Until(now); ok && until.After(at) {
+			at = until
+		}
+	}
+	if nq.bucket != nil {
+		if ready := now.Add(nq.bucket.Wait(now)); ready.After(at) {
+			at = ready
+		}
+	}
+	return at.Sub(now), true
+}
+
+// deliver 把队列中的事件合并成一条消息发送，超过 batchMaxEvents 的留到下一条
+func (nq *notificationQueue) deliver(now time.Time) {
+	nq.mutex.Lock()
+	batch := nq.pending
+	if len(batch) > batchMaxEvents {
+		batch = batch[:batchMaxEvents]
+	}
+	nq.pending = append([]*Event(nil), nq.pending[len(batch):]...)
+	nq.deadline = now
+	if nq.bucket != nil {
+		nq.bucket.Take(now)
+	}
+	nq.mutex.Unlock()
+
+	nq.send(mergeEvents(batch))
+}
+
+// send 发送事件，失败时放入重试队列
+func (nq *notificationQueue) send(event *Event) {
+	if err := deliverEvent(nq.notifier, event, nil); err != nil && nq.outbox != nil {
+		nq.outbox.Add(nq.notifier.Name(), event, err)
+	}
+}
+
+// shutdown 程序退出时立即发送剩余的事件，免打扰时段中暂存的事件保存到重试队列，时段结束后发送
+func (nq *notificationQueue) shutdown() {
+	nq.mutex.Lock()
+	pending := nq.pending
+	nq.pending = nil
+	nq.mutex.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	var quietUntil time.Time
+	if nq.quiet != nil {
+		quietUntil, _ = nq.quiet.Until(time.Now())
+	}
+	if !quietUntil.IsZero() && nq.outbox == nil {
+		log.Printf("🌙 免打扰时段中暂存的 %d 条通知未发送 [%s]", len(pending), nq.notifier.Name())
+		return
+	}
+
+	for len(pending) > 0 {
+		n := len(pending)
+		if n > batchMaxEvents {
+			n = batchMaxEvents
+		}
+		event := mergeEvents(pending[:n])
+		pending = pending[n:]
+
+		if !quietUntil.IsZero() {
+			nq.outbox.Defer(nq.notifier.Name(), event, quietUntil)
+			log.Printf("🌙 免打扰时段中暂存的通知已保存，%s 后发送 [%s]", quietUntil.Format("01-02 15:04"), nq.notifier.Name())
+			continue
+		}
+		nq.send(event)
+	}
+}
+
+// mergeEvents 多个事件合并成一条汇总事件，只有一个事件时原样返回
+func mergeEvents(events []*Event) *Event {
+	if len(events) == 1 {
+		return events[0]
+	}
+	return &Event{Type: EventSummary, Events: events, Time: time.Now()}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTorrentSize 种子文件大小上限
+const maxTorrentSize = 10 * 1024 * 1024
+
+// TorrentMeta 种子文件中的元信息
+type TorrentMeta struct {
+	InfoHash string   // v1 infohash（小写十六进制）
+	Name     string   // 种子名称
+	Length   int64    // 文件总大小（字节）
+	Trackers []string // announce 与 announce-list 中的tracker
+}
+
+// parseTorrentFile 解析种子文件，计算info字典的SHA-1作为infohash
+func parseTorrentFile(data []byte) (*TorrentMeta, error) {
+	raw := make(map[string][]byte)
+	root, err := decodeBencodeDict(data, raw)
+	if err != nil {
+		return nil, fmt.Errorf("解析种子文件失败: %v", err)
+	}
+
+	info, ok := root["info"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("种子文件缺少info字典")
+	}
+
+	sum := sha1.Sum(raw["info"])
+	meta := &TorrentMeta{
+		InfoHash: hex.EncodeToString(sum[:]),
+	}
+
+	// 优先使用 name.utf-8
+	if name, ok := info["name.utf-8"].(string); ok && name != "" {
+		meta.Name = name
+	} else if name, ok := info["name"].(string); ok {
+		meta.Name = name
+	}
+
+	// 单文件种子直接有length，多文件种子需要累加files中的length
+	if length, ok := info["length"].(int64); ok {
+		meta.Length = length
+	} else if files, ok := info["files"].([]interface{}); ok {
+		for _, f := range files {
+			if file, ok := f.(map[string]interface{}); ok {
+				if length, ok := file["length"].(int64); ok {
+					meta.Length += length
+				}
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	addTracker := func(tracker string) {
+		tracker = strings.TrimSpace(tracker)
+		if tracker != "" && !seen[tracker] {
+			seen[tracker] = true
+			meta.Trackers = append(meta.Trackers, tracker)
+		}
+	}
+
+	if announce, ok := root["announce"].(string); ok {
+		addTracker(announce)
+	}
+	if tiers, ok := root["announce-list"].([]interface{}); ok {
+		for _, tier := range tiers {
+			if list, ok := tier.([]interface{}); ok {
+				for _, tracker := range list {
+					if s, ok := tracker.(string); ok {
+						addTracker(s)
+					}
+				}
+			}
+		}
+	}
+
+	return meta, nil
+}
+
+// MagnetLink 根据元信息构造磁力链接，包含 dn、xl 与 tr 参数
+func (tm *TorrentMeta) MagnetLink() string {
+	var sb strings.Builder
+	sb.WriteString("magnet:?xt=urn:btih:")
+	sb.WriteString(tm.InfoHash)
+
+	if tm.Name != "" {
+		sb.WriteString("&dn=")
+		sb.WriteString(url.QueryEscape(tm.Name))
+	}
+	if tm.Length > 0 {
+		sb.WriteString("&xl=")
+		sb.WriteString(strconv.FormatInt(tm.Length, 10))
+	}
+	for _, tracker := range tm.Trackers {
+		sb.WriteString("&tr=")
+		sb.WriteString(url.QueryEscape(tracker))
+	}
+
+	return sb.String()
+}
+
+// fetchTorrent 下载并解析种子文件
+func (bm *BangumiMonitor) fetchTorrent(ctx context.Context, torrentURL string) (*TorrentMeta, error) {
+	_, meta, err := downloadTorrentFile(ctx, torrentURL)
+	return meta, err
+}
+
+// downloadTorrentFile 下载种子文件，返回文件内容和解析出的元信息
+func downloadTorrentFile(ctx context.Context, torrentURL string) ([]byte, *TorrentMeta, error) {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", torrentURL, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("下载种子文件失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, nil, fmt.Errorf("种子文件请求失败，状态码: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTorrentSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取种子文件失败: %v", err)
+	}
+	if len(data) > maxTorrentSize {
+		return nil, nil, fmt.Errorf("种子文件过大 (超过 %d 字节)", maxTorrentSize)
+	}
+
+	meta, err := parseTorrentFile(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, meta, nil
+}
+
+// isTorrentURL 判断是否为种子文件下载地址
+func isTorrentURL(link string) bool {
+	if link == "" || strings.HasPrefix(link, "magnet:") {
+		return false
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return strings.HasSuffix(strings.ToLower(u.Path), ".torrent")
+}
+
+// convertTorrentToMagnet 下载种子文件并在本地转换为磁力链接
+func (bm *BangumiMonitor) convertTorrentToMagnet(ctx context.Context, torrentURL string) (string, *TorrentMeta, error) {
+	log.Printf("📄 下载种子文件: %s", torrentURL)
+
+	meta, err := bm.fetchTorrent(ctx, torrentURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	magnetLink := meta.MagnetLink()
+	log.Printf("🧲 种子已转换为磁力链接: %s (infohash: %s, 大小: %.2f MB, tracker: %d 个)",
+		meta.Name, meta.InfoHash, float64(meta.Length)/(1024*1024), len(meta.Trackers))
+
+	return magnetLink, meta, nil
+}
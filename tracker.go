@@ -0,0 +1,395 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// trackerStoreFile 跟踪中任务的保存文件
+const trackerStoreFile = "tasks.json"
+
+// TrackedTask 已提交、等待完成的下载任务
+type TrackedTask struct {
+	ID           string    `json:"id"`
+	FileName     string    `json:"file_name"`
+	Title        string    `json:"title"`
+	Subscription string    `json:"subscription,omitempty"`
+	Downloader   string    `json:"downloader,omitempty"` // 为空表示 pikpak
+	InfoHash     string    `json:"infohash,omitempty"`
+	FolderID     string    `json:"folder_id,omitempty"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+	Retries      int       `json:"retries"`
+	LastPhase    string    `json:"last_phase,omitempty"`
+}
+
+// TaskResult 任务结束时的结果
+type TaskResult struct {
+	Task     *TrackedTask
+	Success  bool
+	FileID   string
+	FileSize int64
+	Duration time.Duration
+	Message  string // 失败原因
+}
+
+// taskFinder 可以按ID批量查询任务的下载器，未实现时通过 ListTasks 查找
+type taskFinder interface {
+	FindTasks(taskIds map[string]bool) (map[string]*DownloadTask, error)
+}
+
+// TaskTracker 定期轮询各下载器的任务，任务完成或失败时回调
+type TaskTracker struct {
+	downloaders  map[string]Downloader
+	path         string
+	interval     time.Duration
+	maxRetries   int
+	timeout      time.Duration
+	onFinish     func(result *TaskResult)
+	tasks        map[string]*TrackedTask
+	mutex        sync.Mutex
+	pollingMutex sync.Mutex
+}
+
+// NewTaskTracker 创建任务跟踪器，并加载上次未完成的任务
+func NewTaskTracker(downloaders map[string]Downloader, config *Config, onFinish func(result *TaskResult)) (*TaskTracker, error) {
+	dataDir := config.DataDir
+	if dataDir == "" {
+		dataDir = "data"
+	}
+
+	interval := time.Duration(config.Tracker.PollIntervalMinutes) * time.Minute
+	if interval == 0 {
+		interval = 2 * time.Minute
+	}
+
+	timeout := time.Duration(config.Tracker.TimeoutHours) * time.Hour
+	if timeout == 0 {
+		timeout = 72 * time.Hour
+	}
+
+	tracker := &TaskTracker{
+		downloaders: downloaders,
+		path:        filepath.Join(dataDir, trackerStoreFile),
+		interval:    interval,
+		maxRetries:  config.Tracker.MaxRetries,
+		timeout:     timeout,
+		onFinish:    onFinish,
+		tasks:       make(map[string]*TrackedTask),
+	}
+
+	if err := tracker.load(); err != nil {
+		return nil, err
+	}
+
+	if len(tracker.tasks) > 0 {
+		log.Printf("📋 恢复跟踪 %d 个未完成的下载任务", len(tracker.tasks))
+	}
+
+	return tracker, nil
+}
+
+// load 读取保存的任务
+func (tt *TaskTracker) load() error {
+	data, err := os.ReadFile(tt.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取任务跟踪文件失败: %v", err)
+	}
+
+	var tasks []*TrackedTask
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return fmt.Errorf("解析任务跟踪文件失败: %v", err)
+	}
+
+	for _, task := range tasks {
+		tt.tasks[task.ID] = task
+	}
+	return nil
+}
+
+// save 保存任务列表，调用方需持有锁
+func (tt *TaskTracker) save() error {
+	tasks := make([]*TrackedTask, 0, len(tt.tasks))
+	for _, task := range tt.tasks {
+		tasks = append(tasks, task)
+	}
+
+	data, err := json.MarshalIndent(tasks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("编码任务列表失败: %v", err)
+	}
+
+	// 先写临时文件再重命名，避免写到一半被中断
+	tmp := tt.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入任务跟踪文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, tt.path); err != nil {
+		return fmt.Errorf("写入任务跟踪文件失败: %v", err)
+	}
+	return nil
+}
+
+// Track 开始跟踪一个新提交的任务
+func (tt *TaskTracker) Track(task *TrackedTask) {
+	if task.ID == "" {
+		return
+	}
+	if task.SubmittedAt.IsZero() {
+		task.SubmittedAt = time.Now()
+	}
+
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	tt.tasks[task.ID] = task
+	if err := tt.save(); err != nil {
+		log.Printf("❌ 保存任务跟踪失败: %v", err)
+	}
+
+	log.Printf("📌 开始跟踪任务: %s (%s)", task.FileName, task.ID)
+}
+
+// Untrack 停止跟踪任务（任务已被手动删除）
+func (tt *TaskTracker) Untrack(taskID string) {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	if _, ok := tt.tasks[taskID]; !ok {
+		return
+	}
+	delete(tt.tasks, taskID)
+	if err := tt.save(); err != nil {
+		log.Printf("❌ 保存任务跟踪失败: %v", err)
+	}
+
+	log.Printf("📌 停止跟踪任务: %s", taskID)
+}
+
+// Tasks 返回当前跟踪中的任务
+func (tt *TaskTracker) Tasks() []*TrackedTask {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	tasks := make([]*TrackedTask, 0, len(tt.tasks))
+	for _, task := range tt.tasks {
+		copied := *task
+		tasks = append(tasks, &copied)
+	}
+	return tasks
+}
+
+// Run 定期轮询任务状态，直到ctx被取消
+func (tt *TaskTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(tt.interval)
+	defer ticker.Stop()
+
+	log.Printf("📋 任务跟踪器已启动 (轮询间隔: %v, 最大重试: %d 次)", tt.interval, tt.maxRetries)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("👋 任务跟踪器已停止")
+			return
+		case <-ticker.C:
+			tt.Poll()
+		}
+	}
+}
+
+// Poll 查询一次所有跟踪中任务的状态
+func (tt *TaskTracker) Poll() {
+	// 避免定时轮询与手动触发同时进行
+	tt.pollingMutex.Lock()
+	defer tt.pollingMutex.Unlock()
+
+	// 按下载器分组
+	tt.mutex.Lock()
+	groups := make(map[string]map[string]bool)
+	for id, task := range tt.tasks {
+		name := task.downloaderName()
+		if groups[name] == nil {
+			groups[name] = make(map[string]bool)
+		}
+		groups[name][id] = true
+	}
+	tt.mutex.Unlock()
+
+	if len(groups) == 0 {
+		return
+	}
+
+	remote := make(map[string]*DownloadTask)
+	failed := make(map[string]bool)
+	for name, ids := range groups {
+		found, err := tt.findTasks(name, ids)
+		if err != nil {
+			log.Printf("❌ 查询任务状态失败 (%s): %v", name, err)
+			failed[name] = true
+			continue
+		}
+		for id, task := range found {
+			remote[id] = task
+		}
+	}
+
+	var results []*TaskResult
+	var retries []TrackedTask
+
+	tt.mutex.Lock()
+	for id, task := range tt.tasks {
+		// 查询失败的下载器本轮跳过，避免误判为超时
+		if failed[task.downloaderName()] {
+			continue
+		}
+		result, retry := tt.check(task, remote[id])
+		if result != nil {
+			delete(tt.tasks, id)
+			results = append(results, result)
+		}
+		if retry {
+			retries = append(retries, *task)
+		}
+	}
+	if err := tt.save(); err != nil {
+		log.Printf("❌ 保存任务跟踪失败: %v", err)
+	}
+	tt.mutex.Unlock()
+
+	// 重试需要调用下载器，回调可能比较慢（发送通知），都不持有锁
+	for _, task := range retries {
+		if err := tt.downloaders[task.downloaderName()].RetryTask(task.ID); err != nil {
+			log.Printf("❌ 重试任务失败: %v", err)
+		}
+	}
+	for _, result := range results {
+		if tt.onFinish != nil {
+			tt.onFinish(result)
+		}
+	}
+}
+
+// findTasks 查询指定下载器中的任务
+func (tt *TaskTracker) findTasks(name string, ids map[string]bool) (map[string]*DownloadTask, error) {
+	downloader, ok := tt.downloaders[name]
+	if !ok {
+		return nil, fmt.Errorf("下载器未配置")
+	}
+
+	if finder, ok := downloader.(taskFinder); ok {
+		return finder.FindTasks(ids)
+	}
+
+	tasks, err := downloader.ListTasks()
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]*DownloadTask)
+	for _, task := range tasks {
+		if ids[task.ID] {
+			found[task.ID] = task
+		}
+	}
+	return found, nil
+}
+
+// downloaderName 任务所属的下载器，旧版本保存的任务没有记录时为 pikpak
+func (task *TrackedTask) downloaderName() string {
+	if task.Downloader == "" {
+		return pikpakDownloaderName
+	}
+	return task.Downloader
+}
+
+// check 检查单个任务，任务结束时返回结果，需要重试时 retry 为 true，由调用方在释放锁后重试
+// 调用方需持有锁
+func (tt *TaskTracker) check(task *TrackedTask, remote *DownloadTask) (result *TaskResult, retry bool) {
+	if remote == nil {
+		// 排队中的任务不会出现在任务列表里，超过时限才视为失败
+		if time.Since(task.SubmittedAt) > tt.timeout {
+			log.Printf("⏰ 任务超时: %s (%s)", task.FileName, task.ID)
+			return &TaskResult{
+				Task:     task,
+				Duration: time.Since(task.SubmittedAt),
+				Message:  fmt.Sprintf("超过 %v 未完成", tt.timeout),
+			}, false
+		}
+		return nil, false
+	}
+
+	if remote.Phase != task.LastPhase {
+		log.Printf("📊 任务状态变化: %s %s -> %s (%d%%)", task.FileName, task.LastPhase, remote.Phase, remote.Progress)
+		task.LastPhase = remote.Phase
+	}
+
+	switch remote.Phase {
+	case TaskPhaseComplete:
+		log.Printf("✅ 任务完成: %s (%s)", task.FileName, formatSize(remote.FileSize))
+		return &TaskResult{
+			Task:     task,
+			Success:  true,
+			FileID:   remote.FileID,
+			FileSize: remote.FileSize,
+			Duration: taskDuration(task, remote),
+		}, false
+
+	case TaskPhaseError:
+		if task.Retries < tt.maxRetries {
+			task.Retries++
+			log.Printf("🔄 任务失败，第 %d/%d 次重试: %s (%s)", task.Retries, tt.maxRetries, task.FileName, remote.Message)
+			return nil, true
+		}
+
+		log.Printf("❌ 任务失败: %s (%s)", task.FileName, remote.Message)
+		return &TaskResult{
+			Task:     task,
+			Duration: taskDuration(task, remote),
+			Message:  remote.Message,
+		}, false
+	}
+
+	return nil, false
+}
+
+// taskDuration 计算任务耗时，优先使用下载器返回的创建和更新时间
+func taskDuration(task *TrackedTask, remote *DownloadTask) time.Duration {
+	created := remote.CreatedAt
+	updated := remote.UpdatedAt
+	if !created.IsZero() && updated.After(created) {
+		return updated.Sub(created)
+	}
+	return time.Since(task.SubmittedAt)
+}
+
+// formatSize 格式化文件大小
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024*1024:
+		return fmt.Sprintf("%.2f GB", float64(size)/(1024*1024*1024))
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.2f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.2f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
+
+// formatDuration 格式化耗时
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d小时%d分", int(d.Hours()), int(d.Minutes())%60)
+	}
+	if d >= time.Minute {
+		return fmt.Sprintf("%d分%d秒", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d秒", int(d.Seconds()))
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transmissionSessionHeader Transmission用于防CSRF的会话头
+const transmissionSessionHeader = "X-Transmission-Session-Id"
+
+// TransmissionDownloader 通过 Transmission RPC 下载，任务ID为种子的infohash
+type TransmissionDownloader struct {
+	config    DownloaderConfig
+	rpcURL    string
+	client    *http.Client
+	sessionID string
+	mutex     sync.Mutex
+}
+
+// transmissionTorrent torrent-get 接口返回的种子信息
+type transmissionTorrent struct {
+	HashString  string  `json:"hashString"`
+	Name        string  `json:"name"`
+	Status      int     `json:"status"`
+	PercentDone float64 `json:"percentDone"`
+	TotalSize   int64   `json:"totalSize"`
+	DownloadDir string  `json:"downloadDir"`
+	Error       int     `json:"error"`
+	ErrorString string  `json:"errorString"`
+	AddedDate   int64   `json:"addedDate"`
+	DoneDate    int64   `json:"doneDate"`
+}
+
+// transmissionFields torrent-get 需要的字段
+var transmissionFields = []string{
+	"hashString", "name", "status", "percentDone", "totalSize", "downloadDir",
+	"error", "errorString", "addedDate", "doneDate",
+}
+
+// NewTransmissionDownloader 创建Transmission下载器，url 未包含路径时使用默认的 /transmission/rpc
+func NewTransmissionDownloader(config DownloaderConfig) *TransmissionDownloader {
+	rpcURL := strings.TrimSuffix(config.URL, "/")
+	if u, err := url.Parse(rpcURL); err == nil && u.Path == "" {
+		rpcURL += "/transmission/rpc"
+	}
+
+	return &TransmissionDownloader{
+		config: config,
+		rpcURL: rpcURL,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name 下载器名称
+func (tr *TransmissionDownloader) Name() string {
+	return tr.config.Name
+}
+
+// call 调用RPC方法，收到409时更新会话ID后重试
+func (tr *TransmissionDownloader) call(method string, arguments interface{}, result interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"method": method, "arguments": arguments})
+	if err != nil {
+		return fmt.Errorf("JSON编码请求失败: %v", err)
+	}
+
+	tr.mutex.Lock()
+	defer tr.mutex.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequest("POST", tr.rpcURL, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("创建HTTP请求失败: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if tr.sessionID != "" {
+			req.Header.Set(transmissionSessionHeader, tr.sessionID)
+		}
+		if tr.config.Username != "" {
+			req.SetBasicAuth(tr.config.Username, tr.config.Password)
+		}
+
+		resp, err := tr.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("请求Transmission失败: %v", err)
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("读取响应失败: %v", err)
+		}
+
+		if resp.StatusCode == http.StatusConflict {
+			tr.sessionID = resp.Header.Get(transmissionSessionHeader)
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Transmission返回错误: HTTP %d", resp.StatusCode)
+		}
+
+		var response struct {
+			Result    string          `json:"result"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return fmt.Errorf("解析Transmission响应失败: %v", err)
+		}
+		if response.Result != "success" {
+			return fmt.Errorf("Transmission返回错误: %s", response.Result)
+		}
+		if result != nil {
+			if err := json.Unmarshal(response.Arguments, result); err != nil {
+				return fmt.Errorf("解析Transmission响应失败: %v", err)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("Transmission会话ID协商失败")
+}
+
+// ResolveFolder 本地下载器直接使用目录路径，未指定时使用配置的保存目录
+func (tr *TransmissionDownloader) ResolveFolder(folderPath string) (string, error) {
+	if folderPath == "" {
+		return tr.config.SavePath, nil
+	}
+	return folderPath, nil
+}
+
+// AddTask 添加磁力链接或种子链接
+func (tr *TransmissionDownloader) AddTask(name, link, folder string) (string, error) {
+	log.Printf("📥 添加Transmission任务: %s", name)
+
+	arguments := map[string]interface{}{"filename": link}
+	if folder != "" {
+		arguments["download-dir"] = folder
+	}
+
+	var result struct {
+		Added     *transmissionTorrent `json:"torrent-added"`
+		Duplicate *transmissionTorrent `json:"torrent-duplicate"`
+	}
+	if err := tr.call("torrent-add", arguments, &result); err != nil {
+		return "", fmt.Errorf("添加Transmission任务失败: %v", err)
+	}
+
+	added := result.Added
+	if added == nil {
+		added = result.Duplicate
+	}
+	if added == nil || added.HashString == "" {
+		return "", fmt.Errorf("添加Transmission任务失败: 响应中没有种子信息")
+	}
+
+	hash := strings.ToLower(added.HashString)
+	log.Printf("✅ Transmission任务添加成功: %s", hash)
+	return hash, nil
+}
+
+// GetTask 查询单个任务
+func (tr *TransmissionDownloader) GetTask(taskID string) (*DownloadTask, error) {
+	tasks, err := tr.listTasks([]string{taskID})
+	if err != nil {
+		return nil, err
+	}
+	return findTaskIn(tasks, taskID)
+}
+
+// ListTasks 列出所有任务
+func (tr *TransmissionDownloader) ListTasks() ([]*DownloadTask, error) {
+	return tr.listTasks(nil)
+}
+
+// listTasks 查询任务，ids 为空时返回全部
+func (tr *TransmissionDownloader) listTasks(ids []string) ([]*DownloadTask, error) {
+	arguments := map[string]interface{}{"fields": transmissionFields}
+	if len(ids) > 0 {
+		arguments["ids"] = ids
+	}
+
+	var result struct {
+		Torrents []transmissionTorrent `json:"torrents"`
+	}
+	if err := tr.call("torrent-get", arguments, &result); err != nil {
+		return nil, fmt.Errorf("获取Transmission任务列表失败: %v", err)
+	}
+
+	tasks := make([]*DownloadTask, 0, len(result.Torrents))
+	for _, t := range result.Torrents {
+		task := &DownloadTask{
+			ID:        strings.ToLower(t.HashString),
+			Name:      t.Name,
+			Phase:     transmissionPhase(t),
+			Progress:  int(t.PercentDone * 100),
+			FileID:    path.Join(t.DownloadDir, t.Name),
+			FileSize:  t.TotalSize,
+			Message:   t.ErrorString,
+			CreatedAt: time.Unix(t.AddedDate, 0),
+		}
+		if t.DoneDate > 0 {
+			task.UpdatedAt = time.Unix(t.DoneDate, 0)
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// transmissionPhase 将Transmission的种子状态转换为统一状态
+// status: 0 暂停, 1 等待校验, 2 校验中, 3 等待下载, 4 下载中, 5 等待做种, 6 做种中
+func transmissionPhase(t transmissionTorrent) string {
+	switch {
+	case t.Error != 0:
+		return TaskPhaseError
+	case t.PercentDone >= 1:
+		return TaskPhaseComplete
+	case t.Status == 3:
+		return TaskPhasePending
+	}
+	return TaskPhaseRunning
+}
+
+// RemoveTask 删除任务
+func (tr *TransmissionDownloader) RemoveTask(taskID string, deleteFiles bool) error {
+	log.Printf("🗑️  删除Transmission任务: %s (删除文件: %v)", taskID, deleteFiles)
+
+	arguments := map[string]interface{}{"ids": []string{taskID}, "delete-local-data": deleteFiles}
+	if err := tr.call("torrent-remove", arguments, nil); err != nil {
+		return fmt.Errorf("删除Transmission任务失败: %v", err)
+	}
+	return nil
+}
+
+// RetryTask 重新校验并继续下载
+func (tr *TransmissionDownloader) RetryTask(taskID string) error {
+	log.Printf("🔄 重试Transmission任务: %s", taskID)
+
+	arguments := map[string]interface{}{"ids": []string{taskID}}
+	if err := tr.call("torrent-verify", arguments, nil); err != nil {
+		return fmt.Errorf("重试Transmission任务失败: %v", err)
+	}
+	if err := tr.call("torrent-start", arguments, nil); err != nil {
+		return fmt.Errorf("重试Transmission任务失败: %v", err)
+	}
+	return nil
+}
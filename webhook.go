@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+)
+
+// WebhookPayload Webhook发送的JSON内容
+type WebhookPayload struct {
+	Event        string            `json:"event"`
+	Title        string            `json:"title"`
+	FileName     string            `json:"file_name,omitempty"`
+	Subscription string            `json:"subscription,omitempty"`
+	Release      *WebhookRelease   `json:"release,omitempty"`
+	Downloader   string            `json:"downloader,omitempty"`
+	TaskID       string            `json:"task_id,omitempty"`
+	FolderID     string            `json:"folder_id,omitempty"`
+	FileSize     int64             `json:"file_size,omitempty"`
+	Duration     int64             `json:"duration_seconds,omitempty"`
+	Retries      int               `json:"retries,omitempty"`
+	Message      string            `json:"message,omitempty"`
+	Feed         string            `json:"feed,omitempty"`
+	ImageURL     string            `json:"image_url,omitempty"`
+	QuotaUsage   int64             `json:"quota_usage,omitempty"`
+	QuotaLimit   int64             `json:"quota_limit,omitempty"`
+	SubmittedAt  *time.Time        `json:"submitted_at,omitempty"`
+	Events       []*WebhookPayload `json:"events,omitempty"` // 汇总消息中合并的事件
+	Timestamp    time.Time         `json:"timestamp"`
+}
+
+// WebhookRelease 标题解析结果
+type WebhookRelease struct {
+	Group      string   `json:"group,omitempty"`
+	Series     string   `json:"series,omitempty"`
+	Aliases    []string `json:"aliases,omitempty"`
+	Season     int      `json:"season,omitempty"`
+	Episode    int      `json:"episode,omitempty"`
+	EpisodeEnd int      `json:"episode_end,omitempty"`
+	Batch      bool     `json:"batch,omitempty"`
+	Version    int      `json:"version,omitempty"`
+	Resolution string   `json:"resolution,omitempty"`
+	Codec      string   `json:"codec,omitempty"`
+	Source     string   `json:"source,omitempty"`
+	Languages  []string `json:"languages,omitempty"`
+}
+
+// newWebhookPayload 将事件转换为Webhook内容
+func newWebhookPayload(event *Event) *WebhookPayload {
+	payload := &WebhookPayload{
+		Event:        event.Type,
+		Title:        event.Title,
+		FileName:     event.FileName,
+		Subscription: event.Subscription,
+		Downloader:   event.Downloader,
+		TaskID:       event.TaskID,
+		FolderID:     event.FolderID,
+		FileSize:     event.FileSize,
+		Duration:     int64(event.Duration.Seconds()),
+		Retries:      event.Retries,
+		Message:      event.Message,
+		Feed:         event.Feed,
+		ImageURL:     event.ImageURL,
+		QuotaUsage:   event.QuotaUsage,
+		QuotaLimit:   event.QuotaLimit,
+		Timestamp:    event.Time,
+	}
+	if !event.SubmittedAt.IsZero() {
+		payload.SubmittedAt = &event.SubmittedAt
+	}
+
+	for _, nested := range event.Events {
+		payload.Events = append(payload.Events, newWebhookPayload(nested))
+	}
+
+	if r := event.Release; r != nil {
+		payload.Release = &WebhookRelease{
+			Group:      r.Group,
+			Series:     r.Series,
+			Aliases:    r.Aliases,
+			Season:     r.Season,
+			Episode:    r.Episode,
+			EpisodeEnd: r.EpisodeEnd,
+			Batch:      r.Batch,
+			Version:    r.Version,
+			Resolution: r.Resolution,
+			Codec:      r.Codec,
+			Source:     r.Source,
+			Languages:  r.Languages,
+		}
+	}
+
+	return payload
+}
+
+// WebhookNotifier 将事件以JSON POST到配置的地址，配置了 secret 时在 X-Signature 中附带签名
+// 发送失败不在这里重试，由重试队列按退避重试，避免阻塞其他通知
+type WebhookNotifier struct {
+	name   string
+	urls   []string
+	secret string
+	client *http.Client
+}
+
+// WebhookOptions Webhook渠道的配置
+type WebhookOptions struct {
+	URL    string   `json:"url"`
+	URLs   []string `json:"urls"`   // 除 url 外的其他地址
+	Secret string   `json:"secret"` // 请求签名的密钥
+}
+
+// newWebhookNotifier 根据通知渠道配置创建Webhook通知
+func newWebhookNotifier(config NotifierConfig) (Notifier, error) {
+	var options WebhookOptions
+	if err := config.decodeOptions(&options); err != nil {
+		return nil, err
+	}
+
+	urls := options.URLs
+	if options.URL != "" {
+		urls = append([]string{options.URL}, urls...)
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("缺少url或urls")
+	}
+
+	return &WebhookNotifier{
+		name:   config.Name,
+		urls:   urls,
+		secret: options.Secret,
+		client: &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+// Name 渠道名称
+func (wn *WebhookNotifier) Name() string {
+	return wn.name
+}
+
+// Send 发送事件到所有地址，部分地址失败时返回 *TargetError
+func (wn *WebhookNotifier) Send(event *Event) error {
+	return wn.send(event, wn.urls)
+}
+
+// SendTo 只发送到 targets 中仍在配置里的地址
+func (wn *WebhookNotifier) SendTo(event *Event, targets []string) error {
+	return wn.send(event, filterTargets(wn.urls, targets))
+}
+
+// send 发送事件到 urls
+func (wn *WebhookNotifier) send(event *Event, urls []string) error {
+	body, err := json.Marshal(newWebhookPayload(event))
+	if err != nil {
+		return fmt.Errorf("JSON编码请求失败: %v", err)
+	}
+
+	var failed []string
+	for _, url := range urls {
+		if err := wn.post(url, event.Type, body); err != nil {
+			log.Printf("❌ 发送Webhook失败 (%s): %v", url, err)
+			failed = append(failed, url)
+		}
+	}
+
+	if len(failed) > 0 {
+		return &TargetError{Failed: failed, Total: len(urls)}
+	}
+	return nil
+}
+
+// post 发送一次请求，非 2xx 状态码作为失败返回
+func (wn *WebhookNotifier) post(url, eventType string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建HTTP请求失败: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bangumipikpak-webhook/1.0")
+	req.Header.Set("X-Event", eventType)
+	if wn.secret != "" {
+		req.Header.Set("X-Signature", webhookSignature(wn.secret, body))
+	}
+
+	resp, err := wn.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送HTTP请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("状态码: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// webhookSignature 请求体的HMAC-SHA256签名，格式为 sha256=<十六进制>
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}